@@ -0,0 +1,16 @@
+package email_notification
+
+import "context"
+
+type actorKey struct{}
+
+// WithActor 在 context 中设置当前操作人（用于版本作者等记录）
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext 获取 context 中的操作人
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
@@ -0,0 +1,166 @@
+package email_notification
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/KOMKZ/go-yogan-domain-email-notification/model"
+)
+
+// CoverageStatus 翻译覆盖状态
+type CoverageStatus string
+
+const (
+	CoverageEnabled  CoverageStatus = "enabled"  // 已启用
+	CoverageDraft    CoverageStatus = "draft"    // 已创建但未上线（草稿、待审核）
+	CoverageDisabled CoverageStatus = "disabled" // 已停用
+	CoverageMissing  CoverageStatus = "missing"  // 无模板或已归档
+)
+
+// LanguageCoverage 触发点在某一语言下的模板情况
+type LanguageCoverage struct {
+	Language         string               `json:"language"`
+	Status           CoverageStatus       `json:"status"`
+	TemplateID       uint                 `json:"template_id,omitempty"`
+	TemplateStatus   model.TemplateStatus `json:"template_status,omitempty"`
+	ContentUpdatedAt *time.Time           `json:"content_updated_at,omitempty"` // 内容最后修改时间（最新版本创建时间）
+	Stale            bool                 `json:"stale"`                        // 内容早于主语言最后修改，翻译可能过期
+}
+
+// TriggerCoverage 单个触发点的翻译覆盖
+type TriggerCoverage struct {
+	TriggerCode string             `json:"trigger_code"`
+	Name        string             `json:"name"`
+	Languages   []LanguageCoverage `json:"languages"` // 与 CoverageReport.Languages 顺序一致
+}
+
+// CoverageReport 翻译覆盖报告
+type CoverageReport struct {
+	PrimaryLanguage string            `json:"primary_language"` // 主语言（默认语言），过期判断的基准
+	Languages       []string          `json:"languages"`
+	Triggers        []TriggerCoverage `json:"triggers"` // 按触发点代码排序
+}
+
+// coveragePageSize 加载模板时的分页大小
+const coveragePageSize = 500
+
+// TranslationCoverage 翻译覆盖报告：已注册触发点 × 语言的模板状态，以及相对主语言的过期检测
+// languages 为空时使用已有模板的全部语言；主语言始终排在第一列
+func (s *Service) TranslationCoverage(ctx context.Context, languages []string) (*CoverageReport, error) {
+	templates, err := s.allTemplates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CoverageReport{PrimaryLanguage: s.defaultLanguage}
+	report.Languages, err = s.coverageLanguages(languages, templates)
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string]*model.Template, len(templates))
+	for i := range templates {
+		t := &templates[i]
+		byKey[t.TriggerCode+"\x00"+t.Language] = t
+	}
+
+	codes := s.registry.Codes()
+	sort.Strings(codes)
+	for _, code := range codes {
+		trigger := TriggerCoverage{TriggerCode: code}
+		if def, ok := s.registry.Get(code); ok {
+			trigger.Name = def.Name
+		}
+
+		for _, lang := range report.Languages {
+			entry, err := s.languageCoverage(ctx, byKey[code+"\x00"+lang])
+			if err != nil {
+				return nil, err
+			}
+			entry.Language = lang
+			trigger.Languages = append(trigger.Languages, entry)
+		}
+
+		// 主语言为第一列
+		primary := trigger.Languages[0]
+		for i := 1; i < len(trigger.Languages); i++ {
+			entry := &trigger.Languages[i]
+			entry.Stale = primary.ContentUpdatedAt != nil && entry.ContentUpdatedAt != nil &&
+				entry.ContentUpdatedAt.Before(*primary.ContentUpdatedAt)
+		}
+		report.Triggers = append(report.Triggers, trigger)
+	}
+	return report, nil
+}
+
+// coverageLanguages 报告的语言列（主语言在前，规范化并去重）
+func (s *Service) coverageLanguages(languages []string, templates []model.Template) ([]string, error) {
+	if len(languages) == 0 {
+		for _, t := range templates {
+			languages = append(languages, t.Language)
+		}
+		sort.Strings(languages)
+	}
+
+	result := []string{s.defaultLanguage}
+	seen := map[string]bool{s.defaultLanguage: true}
+	for _, lang := range languages {
+		normalized, err := NormalizeLanguage(lang)
+		if err != nil {
+			return nil, err
+		}
+		if normalized != "" && !seen[normalized] {
+			seen[normalized] = true
+			result = append(result, normalized)
+		}
+	}
+	return result, nil
+}
+
+// languageCoverage 单个模板的覆盖情况（template 为 nil 表示缺失）
+func (s *Service) languageCoverage(ctx context.Context, template *model.Template) (LanguageCoverage, error) {
+	if template == nil || template.Status == model.TemplateStatusArchived {
+		return LanguageCoverage{Status: CoverageMissing}, nil
+	}
+
+	entry := LanguageCoverage{TemplateID: template.ID, TemplateStatus: template.Status}
+	switch template.Status {
+	case model.TemplateStatusEnabled:
+		entry.Status = CoverageEnabled
+	case model.TemplateStatusDisabled:
+		entry.Status = CoverageDisabled
+	default:
+		entry.Status = CoverageDraft
+	}
+
+	// 每次内容修改都会生成版本，最新版本的创建时间即内容最后修改时间；无版本记录的历史模板按创建时间
+	updatedAt := template.CreatedAt
+	if template.LatestVersion > 0 {
+		version, err := s.versionRepo.Get(ctx, template.ID, template.LatestVersion)
+		if err != nil && !errors.Is(err, ErrTemplateVersionNotFound) {
+			return entry, err
+		}
+		if version != nil {
+			updatedAt = version.CreatedAt
+		}
+	}
+	entry.ContentUpdatedAt = &updatedAt
+	return entry, nil
+}
+
+// allTemplates 分页加载全部模板
+func (s *Service) allTemplates(ctx context.Context) ([]model.Template, error) {
+	var templates []model.Template
+	for page := 1; ; page++ {
+		result, err := s.templateRepo.List(ctx, TemplateFilter{Page: page, PageSize: coveragePageSize})
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, result.Items...)
+		if page >= result.TotalPages {
+			return templates, nil
+		}
+	}
+}
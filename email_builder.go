@@ -0,0 +1,40 @@
+package email_notification
+
+import "reflect"
+
+// setTextBody 为邮件设置纯文本正文（multipart/alternative）
+// 邮件组件的 Builder 提供 TextBody(string) 方法时生效并返回 true；
+// 否则返回 false，此时仅发送 HTML 正文
+func setTextBody(builder any, text string) bool {
+	method := reflect.ValueOf(builder).MethodByName("TextBody")
+	if !method.IsValid() {
+		return false
+	}
+	if t := method.Type(); t.NumIn() != 1 || t.In(0).Kind() != reflect.String {
+		return false
+	}
+	method.Call([]reflect.Value{reflect.ValueOf(text).Convert(method.Type().In(0))})
+	return true
+}
+
+// messageIDOf 读取邮件组件发送结果中的服务商消息 ID（MessageID 字段或方法）
+// 结果为空或不提供消息 ID 时返回空字符串
+func messageIDOf(result any) string {
+	v := reflect.ValueOf(result)
+	if !v.IsValid() || (v.Kind() == reflect.Ptr && v.IsNil()) {
+		return ""
+	}
+	if method := v.MethodByName("MessageID"); method.IsValid() {
+		if t := method.Type(); t.NumIn() == 0 && t.NumOut() == 1 && t.Out(0).Kind() == reflect.String {
+			return method.Call(nil)[0].String()
+		}
+	}
+	v = reflect.Indirect(v)
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	if field := v.FieldByName("MessageID"); field.IsValid() && field.Kind() == reflect.String {
+		return field.String()
+	}
+	return ""
+}
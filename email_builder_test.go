@@ -0,0 +1,48 @@
+package email_notification
+
+import "testing"
+
+type textBuilder struct{ text string }
+
+func (b *textBuilder) TextBody(text string) *textBuilder {
+	b.text = text
+	return b
+}
+
+type htmlOnlyBuilder struct{}
+
+func (b *htmlOnlyBuilder) Body(string) *htmlOnlyBuilder { return b }
+
+func TestSetTextBody(t *testing.T) {
+	builder := &textBuilder{}
+	if !setTextBody(builder, "Hi Tom") || builder.text != "Hi Tom" {
+		t.Errorf("expected text body to be set, got %q", builder.text)
+	}
+	if setTextBody(&htmlOnlyBuilder{}, "Hi Tom") {
+		t.Error("expected builder without TextBody to be reported as unsupported")
+	}
+}
+
+type sendResult struct{ MessageID string }
+
+type idResult struct{}
+
+func (idResult) MessageID() string { return "<m2@example.com>" }
+
+func TestMessageIDOf(t *testing.T) {
+	cases := []struct {
+		result any
+		want   string
+	}{
+		{&sendResult{MessageID: "<m1@example.com>"}, "<m1@example.com>"},
+		{idResult{}, "<m2@example.com>"},
+		{(*sendResult)(nil), ""},
+		{&struct{}{}, ""},
+		{nil, ""},
+	}
+	for _, c := range cases {
+		if got := messageIDOf(c.result); got != c.want {
+			t.Errorf("messageIDOf(%#v) = %q, want %q", c.result, got, c.want)
+		}
+	}
+}
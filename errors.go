@@ -7,16 +7,28 @@ const (
 )
 
 var (
-	ErrTriggerNotFound     = errcode.Register(errcode.New(ModuleCode, 1001, "email_notification", "trigger.not_found", "触发点不存在", 404))
-	ErrTemplateNotFound    = errcode.Register(errcode.New(ModuleCode, 1002, "email_notification", "template.not_found", "邮件模板不存在", 404))
-	ErrTemplateExists      = errcode.Register(errcode.New(ModuleCode, 1003, "email_notification", "template.exists", "该触发点和语言的模板已存在", 400))
-	ErrTemplateDisabled    = errcode.Register(errcode.New(ModuleCode, 1004, "email_notification", "template.disabled", "邮件模板已禁用", 400))
-	ErrTemplateRender      = errcode.Register(errcode.New(ModuleCode, 1005, "email_notification", "template.render_failed", "模板渲染失败", 500))
-	ErrNoRecipient         = errcode.Register(errcode.New(ModuleCode, 1006, "email_notification", "no_recipient", "收件人不能为空", 400))
-	ErrSendFailed          = errcode.Register(errcode.New(ModuleCode, 1007, "email_notification", "send_failed", "邮件发送失败", 500))
-	ErrInvalidInput        = errcode.Register(errcode.New(ModuleCode, 1008, "email_notification", "invalid_input", "输入参数无效", 400))
-	ErrDatabaseError       = errcode.Register(errcode.New(ModuleCode, 1009, "email_notification", "database_error", "数据库操作失败", 500))
-	ErrNotImplemented      = errcode.Register(errcode.New(ModuleCode, 1010, "email_notification", "not_implemented", "功能暂未实现", 501))
-	ErrSendLogNotFound     = errcode.Register(errcode.New(ModuleCode, 1011, "email_notification", "send_log.not_found", "发送日志不存在", 404))
-	ErrServiceNotAvailable = errcode.Register(errcode.New(ModuleCode, 1012, "email_notification", "service.not_available", "邮件通知服务不可用", 503))
+	ErrTriggerNotFound         = errcode.Register(errcode.New(ModuleCode, 1001, "email_notification", "trigger.not_found", "触发点不存在", 404))
+	ErrTemplateNotFound        = errcode.Register(errcode.New(ModuleCode, 1002, "email_notification", "template.not_found", "邮件模板不存在", 404))
+	ErrTemplateExists          = errcode.Register(errcode.New(ModuleCode, 1003, "email_notification", "template.exists", "该触发点和语言的模板已存在", 400))
+	ErrTemplateDisabled        = errcode.Register(errcode.New(ModuleCode, 1004, "email_notification", "template.disabled", "邮件模板已禁用", 400))
+	ErrTemplateRender          = errcode.Register(errcode.New(ModuleCode, 1005, "email_notification", "template.render_failed", "模板渲染失败", 500))
+	ErrNoRecipient             = errcode.Register(errcode.New(ModuleCode, 1006, "email_notification", "no_recipient", "收件人不能为空", 400))
+	ErrSendFailed              = errcode.Register(errcode.New(ModuleCode, 1007, "email_notification", "send_failed", "邮件发送失败", 500))
+	ErrInvalidInput            = errcode.Register(errcode.New(ModuleCode, 1008, "email_notification", "invalid_input", "输入参数无效", 400))
+	ErrDatabaseError           = errcode.Register(errcode.New(ModuleCode, 1009, "email_notification", "database_error", "数据库操作失败", 500))
+	ErrNotImplemented          = errcode.Register(errcode.New(ModuleCode, 1010, "email_notification", "not_implemented", "功能暂未实现", 501))
+	ErrSendLogNotFound         = errcode.Register(errcode.New(ModuleCode, 1011, "email_notification", "send_log.not_found", "发送日志不存在", 404))
+	ErrServiceNotAvailable     = errcode.Register(errcode.New(ModuleCode, 1012, "email_notification", "service.not_available", "邮件通知服务不可用", 503))
+	ErrTemplateVersionNotFound = errcode.Register(errcode.New(ModuleCode, 1013, "email_notification", "template_version.not_found", "模板版本不存在", 404))
+	ErrInvalidStatusTransition = errcode.Register(errcode.New(ModuleCode, 1014, "email_notification", "template.invalid_status_transition", "模板当前状态不允许该操作", 400))
+	ErrTemplateReviewRequired  = errcode.Register(errcode.New(ModuleCode, 1015, "email_notification", "template.review_required", "模板内容需经审核后才能上线", 403))
+	ErrReviewerRequired        = errcode.Register(errcode.New(ModuleCode, 1016, "email_notification", "template.reviewer_required", "审核操作需要审核人身份", 403))
+	ErrSelfReview              = errcode.Register(errcode.New(ModuleCode, 1017, "email_notification", "template.self_review", "不能审核自己提交的模板", 403))
+	ErrTemplateConflict        = errcode.Register(errcode.New(ModuleCode, 1018, "email_notification", "template.conflict", "模板已被他人修改，请刷新后重试", 409))
+	ErrPartialNotFound         = errcode.Register(errcode.New(ModuleCode, 1019, "email_notification", "partial.not_found", "布局或片段不存在", 404))
+	ErrPartialExists           = errcode.Register(errcode.New(ModuleCode, 1020, "email_notification", "partial.exists", "该名称和语言的布局或片段已存在", 400))
+	ErrPartialCycle            = errcode.Register(errcode.New(ModuleCode, 1021, "email_notification", "partial.cycle", "布局或片段存在循环引用", 400))
+	ErrTemplateLintFailed      = errcode.Register(errcode.New(ModuleCode, 1022, "email_notification", "template.lint_failed", "模板检查未通过", 400))
+	ErrIdempotencyKeyUsed      = errcode.Register(errcode.New(ModuleCode, 1023, "email_notification", "idempotency_key_used", "幂等键已被其他发送请求使用", 409))
+	ErrPartialInUse            = errcode.Register(errcode.New(ModuleCode, 1024, "email_notification", "partial.in_use", "布局或片段仍被模板或其他片段引用", 409))
 )
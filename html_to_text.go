@@ -0,0 +1,182 @@
+package email_notification
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// blockTags 块级标签（前后换行）
+var blockTags = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "header": true, "footer": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "table": true, "blockquote": true, "pre": true, "hr": true,
+	"tr": true, "thead": true, "tbody": true, "tfoot": true,
+}
+
+// skipTags 内容不输出的标签
+var skipTags = map[string]bool{
+	"head": true, "title": true, "style": true, "script": true,
+}
+
+var (
+	hrefPattern       = regexp.MustCompile(`(?i)\bhref\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+	whitespacePattern = regexp.MustCompile(`[ \t\r\n\f]+`)
+	blankLinePattern  = regexp.MustCompile(`\n{3,}`)
+)
+
+// HTMLToText 将 HTML 正文转为纯文本：保留段落换行，链接转为脚注，表格按行展平
+func HTMLToText(s string) string {
+	c := &htmlTextConverter{}
+	c.convert(s)
+	return c.result()
+}
+
+type htmlTextConverter struct {
+	buf       strings.Builder
+	links     []string
+	href      string // 当前 <a> 的链接
+	linkText  strings.Builder
+	inLink    bool
+	skipDepth int
+	cellIndex int // 当前行已输出的单元格数
+}
+
+func (c *htmlTextConverter) convert(s string) {
+	for len(s) > 0 {
+		lt := strings.IndexByte(s, '<')
+		if lt < 0 {
+			c.text(s)
+			return
+		}
+		c.text(s[:lt])
+		s = s[lt:]
+
+		// 注释
+		if strings.HasPrefix(s, "<!--") {
+			end := strings.Index(s, "-->")
+			if end < 0 {
+				return
+			}
+			s = s[end+3:]
+			continue
+		}
+
+		gt := strings.IndexByte(s, '>')
+		if gt < 0 {
+			c.text(s)
+			return
+		}
+		c.tag(s[1:gt])
+		s = s[gt+1:]
+	}
+}
+
+func (c *htmlTextConverter) tag(raw string) {
+	closing := strings.HasPrefix(raw, "/")
+	raw = strings.TrimPrefix(raw, "/")
+	fields := strings.Fields(raw)
+	if len(fields) == 0 {
+		return
+	}
+	name := strings.ToLower(strings.TrimRight(fields[0], "/"))
+
+	if skipTags[name] {
+		if closing {
+			if c.skipDepth > 0 {
+				c.skipDepth--
+			}
+		} else if !strings.HasSuffix(raw, "/") {
+			c.skipDepth++
+		}
+		return
+	}
+	if c.skipDepth > 0 {
+		return
+	}
+
+	switch {
+	case name == "br":
+		c.write("\n")
+	case name == "li" && !closing:
+		c.newline()
+		c.write("- ")
+	case name == "tr" && !closing:
+		c.newline()
+		c.cellIndex = 0
+	case (name == "td" || name == "th") && !closing:
+		if c.cellIndex > 0 {
+			c.write(" | ")
+		}
+		c.cellIndex++
+	case name == "a" && !closing:
+		c.href = ""
+		if m := hrefPattern.FindStringSubmatch(raw); m != nil {
+			c.href = html.UnescapeString(m[1] + m[2] + m[3])
+		}
+		c.inLink = true
+		c.linkText.Reset()
+	case name == "a" && closing:
+		c.closeLink()
+	case blockTags[name]:
+		c.newline()
+		if name == "p" || (len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6') {
+			c.write("\n")
+		}
+	}
+}
+
+func (c *htmlTextConverter) closeLink() {
+	if !c.inLink {
+		return
+	}
+	c.inLink = false
+
+	href := strings.TrimSpace(c.href)
+	text := strings.TrimSpace(c.linkText.String())
+	if href == "" || strings.HasPrefix(href, "#") || href == text || strings.TrimPrefix(href, "mailto:") == text {
+		return
+	}
+	c.links = append(c.links, href)
+	c.write(fmt.Sprintf(" [%d]", len(c.links)))
+}
+
+func (c *htmlTextConverter) text(s string) {
+	if c.skipDepth > 0 || s == "" {
+		return
+	}
+	s = whitespacePattern.ReplaceAllString(html.UnescapeString(s), " ")
+	if c.inLink {
+		c.linkText.WriteString(s)
+	}
+	c.write(s)
+}
+
+func (c *htmlTextConverter) write(s string) {
+	c.buf.WriteString(s)
+}
+
+// newline 换行（已在行首时不重复换行）
+func (c *htmlTextConverter) newline() {
+	if str := c.buf.String(); str != "" && !strings.HasSuffix(str, "\n") {
+		c.buf.WriteString("\n")
+	}
+}
+
+func (c *htmlTextConverter) result() string {
+	lines := strings.Split(c.buf.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(whitespacePattern.ReplaceAllString(line, " "))
+	}
+	text := strings.TrimSpace(blankLinePattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+
+	if len(c.links) > 0 {
+		var footnotes strings.Builder
+		for i, link := range c.links {
+			fmt.Fprintf(&footnotes, "\n[%d] %s", i+1, link)
+		}
+		text += "\n" + footnotes.String()
+	}
+	return text
+}
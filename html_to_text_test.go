@@ -0,0 +1,40 @@
+package email_notification
+
+import "testing"
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{
+			name:     "paragraphs and entities",
+			html:     "<html><head><title>T</title><style>p{}</style></head><body><h1>Hi&nbsp;张三</h1><p>Welcome &amp; enjoy.</p></body></html>",
+			expected: "Hi 张三\n\nWelcome & enjoy.",
+		},
+		{
+			name:     "links as footnotes",
+			html:     `<p>Click <a href="https://example.com/verify?a=1&amp;b=2">here</a> or <a href="https://example.com">https://example.com</a></p>`,
+			expected: "Click here [1] or https://example.com\n\n[1] https://example.com/verify?a=1&b=2",
+		},
+		{
+			name:     "table flattened",
+			html:     "<table><tr><th>Item</th><th>Qty</th></tr><tr><td>Book</td><td>2</td></tr></table>",
+			expected: "Item | Qty\nBook | 2",
+		},
+		{
+			name:     "lists and breaks",
+			html:     "<ul><li>One</li><li>Two</li></ul>Line1<br/>Line2<!-- hidden -->",
+			expected: "- One\n- Two\nLine1\nLine2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTMLToText(tt.html); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
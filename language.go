@@ -0,0 +1,202 @@
+package email_notification
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage 默认语言（未指定语言、且回退链中均无可用模板时使用）
+const DefaultLanguage = "zh-CN"
+
+// maxLanguageLength 语言标签最大长度（与语言列宽度一致，BCP 47 建议至少支持 35 个字符）
+const maxLanguageLength = 35
+
+// languageAliases 已废弃的语言代码
+var languageAliases = map[string]string{"iw": "he", "in": "id", "ji": "yi", "jw": "jv", "mo": "ro"}
+
+// NormalizeLanguage 按 BCP 47 规范化语言标签（如 "zh_cn" → "zh-CN"、"zh-hant-tw" → "zh-Hant-TW"）
+// 语言小写、书写系统首字母大写、地区大写；空字符串原样返回，格式非法返回 ErrInvalidInput
+func NormalizeLanguage(tag string) (string, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return "", nil
+	}
+
+	subtags := strings.Split(strings.ToLower(strings.ReplaceAll(tag, "_", "-")), "-")
+	invalid := ErrInvalidInput.WithMsg("语言标签不合法: " + tag)
+	if len(tag) > maxLanguageLength {
+		return "", invalid
+	}
+
+	lang := subtags[0]
+	if !isAlpha(lang) || len(lang) < 2 || len(lang) > 8 || len(lang) == 4 {
+		return "", invalid
+	}
+	if alias, ok := languageAliases[lang]; ok {
+		subtags[0] = alias
+	}
+
+	extension := false // 扩展（单字符前缀）之后的子标签一律小写
+	for i := 1; i < len(subtags); i++ {
+		s := subtags[i]
+		if s == "" || len(s) > 8 || !isAlphanumeric(s) {
+			return "", invalid
+		}
+		switch {
+		case extension:
+		case len(s) == 1:
+			if i == len(subtags)-1 {
+				return "", invalid
+			}
+			extension = true
+		case len(s) == 4 && isAlpha(s):
+			subtags[i] = strings.ToUpper(s[:1]) + s[1:]
+		case len(s) == 2 && isAlpha(s), len(s) == 3 && isDigits(s):
+			subtags[i] = strings.ToUpper(s)
+		}
+	}
+	return strings.Join(subtags, "-"), nil
+}
+
+// LanguageFallbackChain 语言回退链：请求语言 → 逐级截断（pt-BR → pt）→ 配置的回退语言 → 默认语言（去重）
+func LanguageFallbackChain(language string, fallbacks []string, defaultLanguage string) []string {
+	var chain []string
+	seen := make(map[string]bool)
+	add := func(lang string) {
+		if lang != "" && !seen[strings.ToLower(lang)] {
+			seen[strings.ToLower(lang)] = true
+			chain = append(chain, lang)
+		}
+	}
+
+	for lang := language; lang != ""; lang = truncateLanguage(lang) {
+		add(lang)
+	}
+	for _, lang := range fallbacks {
+		add(lang)
+	}
+	add(defaultLanguage)
+	return chain
+}
+
+// truncateLanguage 去掉最后一个子标签（连同其前的扩展单字符前缀）
+func truncateLanguage(tag string) string {
+	i := strings.LastIndex(tag, "-")
+	if i < 0 {
+		return ""
+	}
+	tag = tag[:i]
+	if j := strings.LastIndex(tag, "-"); j >= 0 && len(tag)-j == 2 {
+		return truncateLanguage(tag)
+	}
+	return tag
+}
+
+// languageRange Accept-Language 中的一项
+type languageRange struct {
+	tag string
+	q   float64
+}
+
+// NegotiateLanguage 按 Accept-Language 请求头从可用语言中选择最合适的一项（RFC 4647 lookup）
+// 按权重依次匹配：完全匹配 → 可用语言为其细分（en → en-GB）→ 逐级截断后再匹配；无匹配时返回空字符串
+func NegotiateLanguage(acceptLanguage string, available []string) string {
+	if len(available) == 0 {
+		return ""
+	}
+
+	for _, r := range parseAcceptLanguage(acceptLanguage) {
+		if r.tag == "*" {
+			return available[0]
+		}
+		for lang := r.tag; lang != ""; lang = truncateLanguage(lang) {
+			if match := matchLanguage(lang, available); match != "" {
+				return match
+			}
+		}
+	}
+	return ""
+}
+
+// matchLanguage 在可用语言中查找与 lang 相同或以其为前缀的语言
+func matchLanguage(lang string, available []string) string {
+	for _, a := range available {
+		if strings.EqualFold(a, lang) {
+			return a
+		}
+	}
+	prefix := strings.ToLower(lang) + "-"
+	for _, a := range available {
+		if strings.HasPrefix(strings.ToLower(a), prefix) {
+			return a
+		}
+	}
+	return ""
+}
+
+// parseAcceptLanguage 解析 Accept-Language 请求头，按权重降序返回（忽略非法项与 q=0 项）
+func parseAcceptLanguage(header string) []languageRange {
+	var ranges []languageRange
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(strings.TrimSpace(key), "q") {
+				parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err != nil || parsed < 0 || parsed > 1 {
+					parsed = 0
+				}
+				q = parsed
+			}
+		}
+		if q == 0 {
+			continue
+		}
+
+		if tag != "*" {
+			normalized, err := NormalizeLanguage(tag)
+			if err != nil {
+				continue
+			}
+			tag = normalized
+		}
+		ranges = append(ranges, languageRange{tag: tag, q: q})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	return ranges
+}
+
+func isAlpha(s string) bool {
+	for _, r := range s {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlphanumeric(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
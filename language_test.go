@@ -0,0 +1,89 @@
+package email_notification
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeLanguage(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{input: "", expected: ""},
+		{input: "zh_cn", expected: "zh-CN"},
+		{input: "EN-us", expected: "en-US"},
+		{input: "zh-hant-tw", expected: "zh-Hant-TW"},
+		{input: "es-419", expected: "es-419"},
+		{input: "iw-IL", expected: "he-IL"},
+		{input: "de-CH-1996", expected: "de-CH-1996"},
+		{input: "en-US-u-CA-gregory", expected: "en-US-u-ca-gregory"},
+		{input: "e", wantErr: true},
+		{input: "en--US", wantErr: true},
+		{input: "en_US.UTF-8", wantErr: true},
+		{input: "en-x", wantErr: true},
+		{input: "zh-Hant-CN-x-private1-private2-abcd", expected: "zh-Hant-CN-x-private1-private2-abcd"},
+		{input: "zh-Hant-CN-x-private1-private2-abcde", wantErr: true}, // 超过 35 个字符
+	}
+
+	for _, tt := range tests {
+		result, err := NormalizeLanguage(tt.input)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("%q: expected ErrInvalidInput, got %v", tt.input, err)
+			}
+			continue
+		}
+		if err != nil || result != tt.expected {
+			t.Errorf("%q: expected %q, got %q (%v)", tt.input, tt.expected, result, err)
+		}
+	}
+}
+
+func TestLanguageFallbackChain(t *testing.T) {
+	tests := []struct {
+		language  string
+		fallbacks []string
+		expected  []string
+	}{
+		{"pt-BR", []string{"en"}, []string{"pt-BR", "pt", "en", "zh-CN"}},
+		{"zh-Hant-TW", nil, []string{"zh-Hant-TW", "zh-Hant", "zh", "zh-CN"}},
+		{"en-US-x-custom", []string{"en", "zh-CN"}, []string{"en-US-x-custom", "en-US", "en", "zh-CN"}},
+		{"", []string{"en"}, []string{"en", "zh-CN"}},
+	}
+
+	for _, tt := range tests {
+		chain := LanguageFallbackChain(tt.language, tt.fallbacks, DefaultLanguage)
+		if !reflect.DeepEqual(chain, tt.expected) {
+			t.Errorf("%q: expected %v, got %v", tt.language, tt.expected, chain)
+		}
+	}
+}
+
+func TestNegotiateLanguage(t *testing.T) {
+	available := []string{"de", "en-GB", "pt-BR", "zh-CN"}
+
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{"pt-BR,pt;q=0.9,en;q=0.8", "pt-BR"},
+		{"pt-PT,pt;q=0.9", "pt-BR"},
+		{"en-US,en;q=0.9", "en-GB"},
+		{"fr-FR, de;q=0.5, en;q=0.7", "en-GB"},
+		{"de-AT", "de"},
+		{"zh-cn;q=0.1, ja", "zh-CN"},
+		{"en;q=0, de", "de"},
+		{"fr, *;q=0.1", "de"},
+		{"fr, ja", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if result := NegotiateLanguage(tt.header, available); result != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.header, tt.expected, result)
+		}
+	}
+}
@@ -0,0 +1,459 @@
+package email_notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"text/template/parse"
+	"unicode/utf8"
+)
+
+// LintSeverity 诊断级别
+type LintSeverity string
+
+const (
+	LintSeverityError   LintSeverity = "error"   // 阻止保存
+	LintSeverityWarning LintSeverity = "warning" // 仅提示
+)
+
+// 诊断代码
+const (
+	LintSyntaxError         = "syntax_error"          // 语法错误（含未定义的函数）
+	LintUndeclaredParam     = "undeclared_param"      // 引用了触发点未声明的参数
+	LintUnusedRequiredParam = "unused_required_param" // 必填参数未在主题与正文中使用
+	LintUnsafeConstruct     = "unsafe_construct"      // 不安全写法（对非可信参数使用 safeHTML/safeURL、HTML 上下文不明确等）
+	LintUnknownPartial      = "unknown_partial"       // 引用了不存在的片段或片段循环引用
+)
+
+// LintDiagnostic 模板静态检查诊断
+type LintDiagnostic struct {
+	Field    string       `json:"field"`            // subject / body_html / body_text，模板级诊断为空
+	Line     int          `json:"line,omitempty"`   // 行号（从 1 开始，未知时为 0）
+	Column   int          `json:"column,omitempty"` // 列号（从 1 开始，未知时为 0）
+	Severity LintSeverity `json:"severity"`
+	Code     string       `json:"code"`
+	Param    string       `json:"param,omitempty"` // 相关参数
+	Message  string       `json:"message"`
+}
+
+// LintResult 模板静态检查结果
+type LintResult struct {
+	Diagnostics []LintDiagnostic `json:"diagnostics"`
+}
+
+// HasErrors 是否包含错误级别诊断
+func (r *LintResult) HasErrors() bool {
+	for _, d := range r.Diagnostics {
+		if d.Severity == LintSeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Errors 错误级别诊断
+func (r *LintResult) Errors() []LintDiagnostic {
+	var errs []LintDiagnostic
+	for _, d := range r.Diagnostics {
+		if d.Severity == LintSeverityError {
+			errs = append(errs, d)
+		}
+	}
+	return errs
+}
+
+// TemplateLintError 模板检查未通过（列出全部错误级别诊断）
+type TemplateLintError struct {
+	Diagnostics []LintDiagnostic `json:"diagnostics"`
+}
+
+func (e *TemplateLintError) Error() string {
+	parts := make([]string, 0, len(e.Diagnostics))
+	for _, d := range e.Diagnostics {
+		loc := d.Field
+		if d.Line > 0 {
+			loc += ":" + strconv.Itoa(d.Line)
+			if d.Column > 0 {
+				loc += ":" + strconv.Itoa(d.Column)
+			}
+		}
+		if loc != "" {
+			loc += " "
+		}
+		parts = append(parts, loc+d.Message)
+	}
+	return "模板检查未通过: " + strings.Join(parts, "; ")
+}
+
+// lintField 参与检查的模板字段
+type lintField struct {
+	name string
+	src  string
+	html bool
+}
+
+// LintTemplate 静态检查模板（不保存）：语法错误、未声明的参数、未使用的必填参数、不安全写法
+// 主题与正文以 text/template/parse 解析后遍历字段节点；CreateTemplate / UpdateTemplate 保存前执行，存在错误级别诊断时返回 ErrTemplateLintFailed
+func (s *Service) LintTemplate(ctx context.Context, input CreateTemplateInput) (*LintResult, error) {
+	def, ok := s.registry.Get(input.TriggerCode)
+	if !ok {
+		return nil, ErrTriggerNotFound.WithMsg("触发点不存在: " + input.TriggerCode)
+	}
+	language, err := s.normalizeLanguage(input.Language)
+	if err != nil {
+		return nil, err
+	}
+	set, err := s.partialsFor(ctx, language)
+	if err != nil {
+		return nil, err
+	}
+
+	params := s.registry.GetAllParams(def.Code)
+	declared := map[string]bool{"CurrentYear": true}
+	trusted := make(map[string]bool)
+	for name := range s.commonParams {
+		declared[name] = true
+	}
+	for _, p := range params {
+		declared[p.Name] = true
+		trusted[p.Name] = p.Trusted
+	}
+
+	result := &LintResult{}
+	used := make(map[string]bool)
+	fields := []lintField{
+		{name: "subject", src: input.Subject},
+		{name: "body_html", src: input.BodyHTML, html: true},
+		{name: "body_text", src: input.BodyText},
+	}
+	for _, f := range fields {
+		partials := set.Text
+		if f.html {
+			partials = set.HTML
+		}
+		diags := s.lintField(f, partials, declared, trusted, used, params)
+		result.Diagnostics = append(result.Diagnostics, diags...)
+	}
+
+	for _, p := range params {
+		if p.Required && !used[p.Name] {
+			result.Diagnostics = append(result.Diagnostics, LintDiagnostic{
+				Severity: LintSeverityWarning,
+				Code:     LintUnusedRequiredParam,
+				Param:    p.Name,
+				Message:  "必填参数未使用: " + p.Name,
+			})
+		}
+	}
+	return result, nil
+}
+
+// lintTemplate 保存前检查，存在错误级别诊断时返回 ErrTemplateLintFailed
+func (s *Service) lintTemplate(ctx context.Context, input CreateTemplateInput) error {
+	result, err := s.LintTemplate(ctx, input)
+	if err != nil {
+		return err
+	}
+	if !result.HasErrors() {
+		return nil
+	}
+	lerr := &TemplateLintError{Diagnostics: result.Errors()}
+	return ErrTemplateLintFailed.WithMsg(lerr.Error()).Wrap(lerr)
+}
+
+func (s *Service) lintField(f lintField, partials map[string]string, declared, trusted, used map[string]bool, params []Param) []LintDiagnostic {
+	if f.src == "" {
+		return nil
+	}
+
+	tmpl, err := texttemplate.New(f.name).Funcs(s.engine.textFuncMap("")).Parse(f.src)
+	if err != nil {
+		return []LintDiagnostic{syntaxDiagnostic(f.name, err)}
+	}
+
+	visited := make(map[string]bool)
+	w := &lintWalker{field: f.name, src: f.src, html: f.html, declared: declared, trusted: trusted, used: used, trees: make(map[string]*parse.Tree), visited: visited}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			w.trees[t.Name()] = t.Tree
+		}
+	}
+
+	// 片段中使用的参数同样计入已使用（片段的未声明参数由片段作者负责）
+	names, err := ResolvePartials(f.src, partials)
+	if errors.Is(err, ErrPartialNotFound) || errors.Is(err, ErrPartialCycle) {
+		w.diags = append(w.diags, LintDiagnostic{Field: f.name, Severity: LintSeverityError, Code: LintUnknownPartial, Message: err.Error()})
+	}
+	w.partials = &lintWalker{collectOnly: true, used: used, trees: make(map[string]*parse.Tree), visited: visited}
+	for _, name := range names {
+		t := parse.New(name)
+		t.Mode = parse.SkipFuncCheck
+		if _, err := t.Parse(partials[name], "", "", w.partials.trees); err != nil {
+			continue
+		}
+	}
+
+	// 从字段模板出发遍历，片段按调用处传入的 dot 检查（见 paramScope）
+	w.template(f.name, rootScope)
+
+	// HTML 正文：按上下文转义检查（如在 <script> 或未加引号的属性中插值）
+	if f.html && err == nil && len(w.diags) == 0 {
+		if d, ok := s.checkHTMLEscaping(f, names, partials, params); ok {
+			w.diags = append(w.diags, d)
+		}
+	}
+
+	sort.SliceStable(w.diags, func(i, j int) bool {
+		if w.diags[i].Line != w.diags[j].Line {
+			return w.diags[i].Line < w.diags[j].Line
+		}
+		return w.diags[i].Column < w.diags[j].Column
+	})
+	return w.diags
+}
+
+// checkHTMLEscaping 使用示例参数执行一次 HTML 模板，捕获 html/template 的上下文转义错误
+func (s *Service) checkHTMLEscaping(f lintField, names []string, partials map[string]string, params []Param) (LintDiagnostic, bool) {
+	tmpl, err := htmltemplate.New(rootTemplateName).Funcs(s.engine.htmlFuncMap("")).Parse(f.src)
+	if err != nil {
+		return LintDiagnostic{}, false
+	}
+	for _, name := range names {
+		if _, err := tmpl.New(name).Parse(partials[name]); err != nil {
+			return LintDiagnostic{}, false
+		}
+	}
+
+	err = tmpl.ExecuteTemplate(io.Discard, rootTemplateName, exampleParams(params))
+	var herr *htmltemplate.Error
+	if !errors.As(err, &herr) || herr.ErrorCode == htmltemplate.OK {
+		return LintDiagnostic{}, false
+	}
+	d := LintDiagnostic{
+		Field:    f.name,
+		Severity: LintSeverityError,
+		Code:     LintUnsafeConstruct,
+		Message:  "HTML 转义检查失败: " + herr.Description,
+	}
+	if herr.Name == rootTemplateName {
+		d.Line = herr.Line
+	}
+	return d, true
+}
+
+// syntaxErrorPattern text/template 解析错误格式：template: name:line: message 或 template: name:line:col: message
+var syntaxErrorPattern = regexp.MustCompile(`^template: [^:]*:(\d+):(?:(\d+):)? (.*)$`)
+
+func syntaxDiagnostic(field string, err error) LintDiagnostic {
+	d := LintDiagnostic{Field: field, Severity: LintSeverityError, Code: LintSyntaxError, Message: err.Error()}
+	if m := syntaxErrorPattern.FindStringSubmatch(err.Error()); m != nil {
+		d.Line, _ = strconv.Atoi(m[1])
+		d.Column, _ = strconv.Atoi(m[2])
+		d.Message = m[3]
+	}
+	return d
+}
+
+// lintWalker 遍历模板语法树，检查参数引用与安全函数用法
+type lintWalker struct {
+	field       string
+	src         string
+	html        bool
+	collectOnly bool // 仅收集使用的参数，不产生诊断
+	declared    map[string]bool
+	trusted     map[string]bool
+	used        map[string]bool
+	trees       map[string]*parse.Tree // 可调用的模板（字段内 define 或片段）
+	visited     map[string]bool        // 已遍历的 模板名+作用域
+	partials    *lintWalker            // 片段遍历器（调用的模板不在 trees 中时使用）
+	diags       []LintDiagnostic
+}
+
+// template 以 scope 遍历名为 name 的模板
+func (w *lintWalker) template(name string, scope paramScope) {
+	tree, ok := w.trees[name]
+	if !ok {
+		if w.partials != nil {
+			w.partials.template(name, scope)
+		}
+		return
+	}
+	key := fmt.Sprintf("%s|%t|%t", name, scope.dot, scope.dollar)
+	if w.visited[key] {
+		return
+	}
+	w.visited[key] = true
+	w.walk(tree.Root, scope)
+}
+
+// walk scope 表示当前 dot 与 $ 是否为模板参数
+func (w *lintWalker) walk(node parse.Node, scope paramScope) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			w.walk(child, scope)
+		}
+	case *parse.ActionNode:
+		w.pipe(n.Pipe, scope)
+	case *parse.IfNode:
+		w.pipe(n.Pipe, scope)
+		w.walk(n.List, scope)
+		w.walk(n.ElseList, scope)
+	case *parse.RangeNode:
+		w.pipe(n.Pipe, scope)
+		w.walk(n.List, scope.inner())
+		w.walk(n.ElseList, scope)
+	case *parse.WithNode:
+		w.pipe(n.Pipe, scope)
+		w.walk(n.List, scope.inner())
+		w.walk(n.ElseList, scope)
+	case *parse.TemplateNode:
+		w.pipe(n.Pipe, scope)
+		w.template(n.Name, scope.call(n.Pipe))
+	}
+}
+
+func (w *lintWalker) pipe(p *parse.PipeNode, scope paramScope) {
+	if p == nil {
+		return
+	}
+	for i, cmd := range p.Cmds {
+		for _, arg := range cmd.Args {
+			w.arg(arg, scope)
+		}
+		if !w.html || len(cmd.Args) == 0 || !isSafeFunc(cmd.Args[0]) {
+			continue
+		}
+		// {{safeHTML .X}} 或 {{.X | safeHTML}}
+		var target parse.Node
+		switch {
+		case len(cmd.Args) > 1:
+			target = cmd.Args[1]
+		case i > 0 && len(p.Cmds[i-1].Args) == 1:
+			target = p.Cmds[i-1].Args[0]
+		}
+		w.checkTrusted(cmd.Args[0].(*parse.IdentifierNode), target, scope)
+	}
+}
+
+func (w *lintWalker) arg(node parse.Node, scope paramScope) {
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		if scope.dot {
+			w.param(n.Ident[0], n)
+		}
+	case *parse.VariableNode:
+		if scope.dollar && n.Ident[0] == "$" && len(n.Ident) > 1 {
+			w.param(n.Ident[1], n)
+		}
+	case *parse.ChainNode:
+		w.arg(n.Node, scope)
+	case *parse.PipeNode:
+		w.pipe(n, scope)
+	}
+}
+
+func (w *lintWalker) param(name string, node parse.Node) {
+	w.used[name] = true
+	if w.collectOnly || w.declared[name] {
+		return
+	}
+
+	msg := "未声明的参数: " + name
+	if suggestion := closestParam(name, w.declared); suggestion != "" {
+		msg += "（是否为 " + suggestion + "？）"
+	}
+	w.report(node, LintUndeclaredParam, name, msg)
+}
+
+// checkTrusted safeHTML/safeURL 仅可用于声明为可信的参数
+func (w *lintWalker) checkTrusted(fn *parse.IdentifierNode, target parse.Node, scope paramScope) {
+	if w.collectOnly {
+		return
+	}
+	name := paramName(target, scope)
+	switch {
+	case name == "":
+		w.report(fn, LintUnsafeConstruct, "", fn.Ident+" 只能用于声明为可信的参数")
+	case w.declared[name] && !w.trusted[name]:
+		w.report(fn, LintUnsafeConstruct, name, fn.Ident+" 用于非可信参数: "+name)
+	}
+}
+
+func (w *lintWalker) report(node parse.Node, code, param, msg string) {
+	d := LintDiagnostic{Field: w.field, Severity: LintSeverityError, Code: code, Param: param, Message: msg}
+	d.Line, d.Column = nodePosition(w.src, node)
+	w.diags = append(w.diags, d)
+}
+
+// nodePosition 节点的行列号（列号按字符计，从 1 开始）
+func nodePosition(src string, node parse.Node) (int, int) {
+	pos := int(node.Position())
+	if pos > len(src) {
+		return 0, 0
+	}
+	before := src[:pos]
+	lineStart := strings.LastIndex(before, "\n") + 1
+	return strings.Count(before, "\n") + 1, utf8.RuneCountInString(before[lineStart:]) + 1
+}
+
+func isSafeFunc(node parse.Node) bool {
+	ident, ok := node.(*parse.IdentifierNode)
+	return ok && reservedFuncNames[ident.Ident]
+}
+
+// paramName 参数引用节点（.X 或 $.X）对应的参数名，dot 或 $ 不指向模板参数时为空
+func paramName(node parse.Node, scope paramScope) string {
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		if scope.dot && len(n.Ident) == 1 {
+			return n.Ident[0]
+		}
+	case *parse.VariableNode:
+		if scope.dollar && n.Ident[0] == "$" && len(n.Ident) == 2 {
+			return n.Ident[1]
+		}
+	}
+	return ""
+}
+
+// closestParam 查找拼写相近的已声明参数（编辑距离不超过 2）
+func closestParam(name string, declared map[string]bool) string {
+	best, bestDist := "", 3
+	for candidate := range declared {
+		if d := editDistance(strings.ToLower(name), strings.ToLower(candidate)); d < bestDist || (d == bestDist && candidate < best) {
+			best, bestDist = candidate, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
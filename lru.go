@@ -0,0 +1,107 @@
+package email_notification
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lruCache 并发安全的 LRU 缓存，可选过期时间（ttl 为 0 表示不过期）
+type lruCache[K comparable, V any] struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	now      func() time.Time
+	items    map[K]*list.Element
+	order    *list.List // 队首为最近使用
+}
+
+type lruEntry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+func newLRUCache[K comparable, V any](capacity int, ttl time.Duration) *lruCache[K, V] {
+	return &lruCache[K, V]{
+		capacity: capacity,
+		ttl:      ttl,
+		now:      time.Now,
+		items:    make(map[K]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get 获取缓存值（过期视为未命中）
+func (c *lruCache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	elem, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+	entry := elem.Value.(*lruEntry[K, V])
+	if c.ttl > 0 && c.now().After(entry.expiresAt) {
+		c.removeElement(elem)
+		return zero, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// Add 写入缓存，超出容量时淘汰最久未使用的条目
+func (c *lruCache[K, V]) Add(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expiresAt time.Time
+	if c.ttl > 0 {
+		expiresAt = c.now().Add(c.ttl)
+	}
+
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*lruEntry[K, V])
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&lruEntry[K, V]{key: key, value: value, expiresAt: expiresAt})
+	for c.capacity > 0 && c.order.Len() > c.capacity {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// Remove 删除指定条目
+func (c *lruCache[K, V]) Remove(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// Purge 清空缓存
+func (c *lruCache[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = make(map[K]*list.Element)
+	c.order.Init()
+}
+
+// Len 当前条目数
+func (c *lruCache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *lruCache[K, V]) removeElement(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*lruEntry[K, V]).key)
+}
@@ -0,0 +1,52 @@
+package email_notification
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	c := newLRUCache[string, int](2, 0)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Get("a") // a 最近使用
+	c.Add("c", 3)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected least recently used entry to be evicted")
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("expected a=1, got %d, %v", v, ok)
+	}
+	if c.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", c.Len())
+	}
+
+	c.Remove("a")
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected removed entry to be missing")
+	}
+	c.Purge()
+	if c.Len() != 0 {
+		t.Errorf("expected empty cache after purge, got %d", c.Len())
+	}
+}
+
+func TestLRUCache_TTL(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := newLRUCache[string, int](10, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Add("a", 1)
+	now = now.Add(59 * time.Second)
+	if _, ok := c.Get("a"); !ok {
+		t.Error("expected entry before expiry")
+	}
+	now = now.Add(2 * time.Second)
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected entry to expire")
+	}
+	if c.Len() != 0 {
+		t.Errorf("expected expired entry to be removed, got %d", c.Len())
+	}
+}
@@ -0,0 +1,183 @@
+package email_notification
+
+import (
+	"context"
+	"time"
+
+	"github.com/KOMKZ/go-yogan-domain-email-notification/model"
+	"gorm.io/gorm"
+)
+
+// Migration 版本化数据库迁移
+type Migration struct {
+	Version string                  // 版本号（按字典序执行，执行后不可修改）
+	Name    string                  // 说明
+	Up      func(tx *gorm.DB) error // 迁移逻辑（需幂等）
+}
+
+// uniqueTriggerLanguageIndex (trigger_code, language) 唯一索引（仅约束未删除记录）
+const uniqueTriggerLanguageIndex = "uk_email_templates_trigger_language"
+
+// migrations 全部迁移（只追加，不修改已发布的迁移）
+var migrations = []Migration{
+	{
+		Version: "0001",
+		Name:    "create email tables",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&model.Template{}, &model.SendLog{}, &model.SendJob{})
+		},
+	},
+	{
+		Version: "0002",
+		Name:    "drop legacy non table-scoped indexes",
+		Up: func(tx *gorm.DB) error {
+			legacy := []struct {
+				model any
+				index string
+			}{
+				{&model.Template{}, "idx_trigger"},
+				{&model.Template{}, "idx_status"},
+				{&model.SendLog{}, "idx_trigger"},
+				{&model.SendLog{}, "idx_status"},
+				{&model.SendLog{}, "idx_created"},
+			}
+			for _, l := range legacy {
+				if tx.Migrator().HasIndex(l.model, l.index) {
+					if err := tx.Migrator().DropIndex(l.model, l.index); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: "0003",
+		Name:    "unique trigger_code and language for non-deleted templates",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasIndex(&model.Template{}, uniqueTriggerLanguageIndex) {
+				return nil
+			}
+
+			// MySQL 不支持部分索引：借助生成列，已删除记录该列为 NULL，不参与唯一约束
+			if tx.Dialector.Name() == "mysql" {
+				if !tx.Migrator().HasColumn(&model.Template{}, "alive") {
+					if err := tx.Exec("ALTER TABLE email_templates ADD COLUMN alive TINYINT AS (IF(deleted_at IS NULL, 1, NULL)) VIRTUAL").Error; err != nil {
+						return err
+					}
+				}
+				return tx.Exec("CREATE UNIQUE INDEX " + uniqueTriggerLanguageIndex + " ON email_templates (trigger_code, language, alive)").Error
+			}
+
+			return tx.Exec("CREATE UNIQUE INDEX " + uniqueTriggerLanguageIndex + " ON email_templates (trigger_code, language) WHERE deleted_at IS NULL").Error
+		},
+	},
+	{
+		Version: "0004",
+		Name:    "template versions",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&model.TemplateVersion{}, &model.Template{}, &model.SendLog{})
+		},
+	},
+	{
+		Version: "0005",
+		Name:    "template review fields",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&model.Template{})
+		},
+	},
+	{
+		Version: "0006",
+		Name:    "template audits",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&model.TemplateAudit{})
+		},
+	},
+	{
+		Version: "0007",
+		Name:    "template revision for optimistic locking",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&model.Template{})
+		},
+	},
+	{
+		Version: "0008",
+		Name:    "layouts and partials",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&model.Partial{}, &model.Template{}, &model.TemplateVersion{})
+		},
+	},
+	{
+		Version: "0009",
+		Name:    "send log requested language",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&model.SendLog{})
+		},
+	},
+	{
+		Version: "0010",
+		Name:    "send log parent for resend",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&model.SendLog{})
+		},
+	},
+	{
+		Version: "0011",
+		Name:    "send log idempotency key",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&model.SendLog{})
+		},
+	},
+	{
+		Version: "0012",
+		Name:    "widen language columns to 35",
+		Up: func(tx *gorm.DB) error {
+			// SQLite 不限制 VARCHAR 长度
+			if tx.Dialector.Name() == "sqlite" {
+				return nil
+			}
+			for _, m := range []any{&model.Template{}, &model.Partial{}, &model.SendLog{}} {
+				if err := tx.Migrator().AlterColumn(m, "Language"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// Migrate 执行邮件通知模块的数据库迁移（幂等，可在每次启动时调用）
+func Migrate(ctx context.Context, db *gorm.DB) error {
+	db = db.WithContext(ctx)
+
+	if err := db.AutoMigrate(&model.SchemaMigration{}); err != nil {
+		return ErrDatabaseError.Wrap(err)
+	}
+
+	var applied []model.SchemaMigration
+	if err := db.Find(&applied).Error; err != nil {
+		return ErrDatabaseError.Wrap(err)
+	}
+	done := make(map[string]bool, len(applied))
+	for _, m := range applied {
+		done[m.Version] = true
+	}
+
+	for _, m := range migrations {
+		if done[m.Version] {
+			continue
+		}
+
+		// MySQL 的 DDL 会隐式提交，因此每个迁移自身必须幂等，失败后可安全重跑
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&model.SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return ErrDatabaseError.WithMsg("数据库迁移失败: " + m.Version + " " + m.Name).Wrap(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package email_notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/KOMKZ/go-yogan-domain-email-notification/model"
+)
+
+func TestMigrate(t *testing.T) {
+	db := newTestDB(t) // 已执行一次 Migrate
+	ctx := context.Background()
+
+	// 重复执行幂等
+	if err := Migrate(ctx, db); err != nil {
+		t.Fatalf("migrate again: %v", err)
+	}
+
+	var count int64
+	db.Model(&model.SchemaMigration{}).Count(&count)
+	if int(count) != len(migrations) {
+		t.Errorf("expected %d applied migrations, got %d", len(migrations), count)
+	}
+
+	// 唯一索引仅约束未删除记录
+	first := &model.Template{TriggerCode: "user:registered", Language: "zh-CN", Name: "a", Subject: "s", BodyHTML: "b"}
+	if err := db.Create(first).Error; err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	duplicate := &model.Template{TriggerCode: "user:registered", Language: "zh-CN", Name: "b", Subject: "s", BodyHTML: "b"}
+	if err := db.Create(duplicate).Error; err == nil {
+		t.Fatal("expected unique violation for duplicate trigger and language")
+	}
+
+	db.Delete(first)
+	if err := db.Create(&model.Template{TriggerCode: "user:registered", Language: "zh-CN", Name: "c", Subject: "s", BodyHTML: "b"}).Error; err != nil {
+		t.Errorf("expected create after soft delete to succeed, got %v", err)
+	}
+}
+
+func TestService_SeedTemplates(t *testing.T) {
+	svc, _ := newTestService(t) // 已存在 user:registered/zh-CN
+	svc.registry.Register("order:shipped", "订单发货", "", nil)
+	ctx := context.Background()
+
+	fsys := fstest.MapFS{
+		"templates/user_registered.zh-CN.json": {Data: []byte(`{"trigger_code":"user:registered","name":"注册欢迎","subject":"Hi","body_html":"<p>Hi</p>"}`)},
+		"templates/order_shipped.en-US.json":   {Data: []byte(`{"trigger_code":"order:shipped","language":"en-US","name":"Shipped","subject":"Shipped","body_html_file":"order_shipped.en-US.html"}`)},
+		"templates/order_shipped.en-US.html":   {Data: []byte(`<p>Your order has shipped</p>`)},
+	}
+
+	result, err := svc.SeedTemplates(ctx, fsys)
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	if len(result.Created) != 1 || result.Created[0] != "order:shipped/en-US" {
+		t.Errorf("unexpected created: %v", result.Created)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0] != "user:registered/zh-CN" {
+		t.Errorf("unexpected skipped: %v", result.Skipped)
+	}
+
+	tpl, err := svc.GetTemplateByTrigger(ctx, "order:shipped", "en-US")
+	if err != nil {
+		t.Fatalf("expected seeded template to be enabled: %v", err)
+	}
+	if tpl.BodyHTML != "<p>Your order has shipped</p>" {
+		t.Errorf("expected body loaded from file, got %s", tpl.BodyHTML)
+	}
+
+	// 再次导入全部跳过
+	result, _ = svc.SeedTemplates(ctx, fsys)
+	if len(result.Created) != 0 || len(result.Skipped) != 2 {
+		t.Errorf("expected all skipped on second seed, got %+v", result)
+	}
+
+	// 种子状态仅支持 draft 与 enabled
+	svc.registry.Register("order:cancelled", "订单取消", "", nil)
+	bad := fstest.MapFS{
+		"order_cancelled.json": {Data: []byte(`{"trigger_code":"order:cancelled","status":"disabled","subject":"Cancelled","body_html":"<p>Cancelled</p>"}`)},
+	}
+	if _, err := svc.SeedTemplates(ctx, bad); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for disabled seed, got %v", err)
+	}
+	if exists, _ := svc.templateRepo.ExistsByTriggerAndLanguage(ctx, "order:cancelled", DefaultLanguage, 0); exists {
+		t.Error("expected no template created for rejected seed")
+	}
+
+	draft := fstest.MapFS{
+		"order_cancelled.json": {Data: []byte(`{"trigger_code":"order:cancelled","status":"draft","subject":"Cancelled","body_html":"<p>Cancelled</p>"}`)},
+	}
+	if _, err := svc.SeedTemplates(ctx, draft); err != nil {
+		t.Fatalf("seed draft: %v", err)
+	}
+	if _, err := svc.GetTemplateByTrigger(ctx, "order:cancelled", DefaultLanguage); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("expected draft seed not to be sendable, got %v", err)
+	}
+}
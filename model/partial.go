@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// PartialKind 片段类型
+type PartialKind string
+
+const (
+	PartialKindPartial PartialKind = "partial" // 片段：模板中通过 {{template "name" .}} 引用
+	PartialKindLayout  PartialKind = "layout"  // 布局：通过 {{.Content}} 包裹模板正文
+)
+
+// Partial 共享的布局与片段（页眉、页脚、退订说明等），按名称和语言区分
+type Partial struct {
+	ID          uint        `json:"id" gorm:"primaryKey"`
+	Name        string      `json:"name" gorm:"size:100;not null;uniqueIndex:uk_email_partials_name_language,priority:1"`
+	Language    string      `json:"language" gorm:"size:35;not null;default:zh-CN;uniqueIndex:uk_email_partials_name_language,priority:2"`
+	Kind        PartialKind `json:"kind" gorm:"size:20;not null;default:partial"`
+	Description string      `json:"description" gorm:"size:500"`
+	BodyHTML    string      `json:"body_html" gorm:"type:text;not null"`
+	BodyText    string      `json:"body_text" gorm:"type:text"` // 纯文本版本（为空时纯文本正文不使用该片段/布局）
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// TableName 表名
+func (Partial) TableName() string {
+	return "email_partials"
+}
+
+// IsLayout 是否为布局
+func (p *Partial) IsLayout() bool {
+	return p.Kind == PartialKindLayout
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// SchemaMigration 已执行的数据库迁移记录
+type SchemaMigration struct {
+	Version   string    `json:"version" gorm:"primaryKey;size:50"`
+	Name      string    `json:"name" gorm:"size:200;not null"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// TableName 表名
+func (SchemaMigration) TableName() string {
+	return "email_schema_migrations"
+}
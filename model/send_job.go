@@ -0,0 +1,59 @@
+package model
+
+import "time"
+
+// JobStatus 队列任务状态
+type JobStatus string
+
+const (
+	JobStatusQueued     JobStatus = "queued"
+	JobStatusProcessing JobStatus = "processing"
+	JobStatusDone       JobStatus = "done"
+	JobStatusFailed     JobStatus = "failed"
+)
+
+// SendJob 异步发送队列任务
+type SendJob struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	SendLogID   uint       `json:"send_log_id" gorm:"not null;index"`
+	TriggerCode string     `json:"trigger_code" gorm:"size:100;not null"`
+	Payload     string     `json:"payload" gorm:"type:json"` // 序列化后的 SendInput
+	Status      JobStatus  `json:"status" gorm:"size:20;not null;default:queued;index:idx_email_send_jobs_claim,priority:1"`
+	Attempts    int        `json:"attempts" gorm:"not null;default:0"`
+	AvailableAt time.Time  `json:"available_at" gorm:"not null;index:idx_email_send_jobs_claim,priority:2"`
+	LockedBy    string     `json:"locked_by" gorm:"size:100"`
+	LockedUntil *time.Time `json:"locked_until"`
+	LastError   string     `json:"last_error" gorm:"type:text"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName 表名
+func (SendJob) TableName() string {
+	return "email_send_jobs"
+}
+
+// MarkDone 标记为已完成
+func (j *SendJob) MarkDone() {
+	j.Status = JobStatusDone
+	j.LockedBy = ""
+	j.LockedUntil = nil
+	j.LastError = ""
+}
+
+// MarkFailed 标记为失败
+func (j *SendJob) MarkFailed(errMsg string) {
+	j.Status = JobStatusFailed
+	j.LockedBy = ""
+	j.LockedUntil = nil
+	j.LastError = errMsg
+}
+
+// Requeue 重新入队，在 availableAt 之后可被再次领取
+func (j *SendJob) Requeue(availableAt time.Time, errMsg string) {
+	j.Status = JobStatusQueued
+	j.AvailableAt = availableAt
+	j.LockedBy = ""
+	j.LockedUntil = nil
+	j.LastError = errMsg
+}
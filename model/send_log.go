@@ -1,29 +1,49 @@
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // SendStatus 发送状态
 type SendStatus string
 
 const (
-	SendStatusPending SendStatus = "pending"
-	SendStatusSent    SendStatus = "sent"
-	SendStatusFailed  SendStatus = "failed"
+	SendStatusPending  SendStatus = "pending"
+	SendStatusQueued   SendStatus = "queued"
+	SendStatusRetrying SendStatus = "retrying"
+	SendStatusSent     SendStatus = "sent"
+	SendStatusFailed   SendStatus = "failed"
 )
 
 // SendLog 邮件发送日志
 type SendLog struct {
-	ID           uint       `json:"id" gorm:"primaryKey"`
-	TemplateID   *uint      `json:"template_id" gorm:"index"`
-	TriggerCode  string     `json:"trigger_code" gorm:"size:100;not null;index:idx_trigger"`
-	Language     string     `json:"language" gorm:"size:10;not null"`
-	Recipient    string     `json:"recipient" gorm:"size:500;not null"`
-	Subject      string     `json:"subject" gorm:"size:500;not null"`
-	Params       string     `json:"params" gorm:"type:json"`
-	Status       SendStatus `json:"status" gorm:"size:20;not null;default:pending;index:idx_status"`
-	ErrorMessage string     `json:"error_message" gorm:"type:text"`
-	SentAt       *time.Time `json:"sent_at"`
-	CreatedAt    time.Time  `json:"created_at" gorm:"index:idx_created"`
+	ID                uint       `json:"id" gorm:"primaryKey"`
+	ParentLogID       *uint      `json:"parent_log_id" gorm:"index"` // 重发时指向原日志
+	TemplateID        *uint      `json:"template_id" gorm:"index"`
+	TemplateVersion   int        `json:"template_version" gorm:"not null;default:0"` // 发送时使用的模板版本
+	TriggerCode       string     `json:"trigger_code" gorm:"size:100;not null;index:idx_email_send_logs_trigger"`
+	Language          string     `json:"language" gorm:"size:35;not null"`  // 实际使用的模板语言（按回退链解析后）
+	RequestedLanguage string     `json:"requested_language" gorm:"size:35"` // 请求的语言（规范化后，未指定时为空）
+	Recipient         string     `json:"recipient" gorm:"size:500;not null;uniqueIndex:uk_email_send_logs_idempotency,priority:2"`
+	Subject           string     `json:"subject" gorm:"size:500;not null"`
+	Params            string     `json:"params" gorm:"type:json"`
+	Status            SendStatus `json:"status" gorm:"size:20;not null;default:pending;index:idx_email_send_logs_status"`
+	ErrorMessage      string     `json:"error_message" gorm:"type:text"`
+	Attempts          int        `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt     *time.Time `json:"next_attempt_at"`
+	ErrorHistory      string     `json:"error_history" gorm:"type:text"` // JSON 数组，每次失败的错误记录
+	MessageID         string     `json:"message_id" gorm:"size:255"`     // 服务商消息 ID
+	SentAt            *time.Time `json:"sent_at"`
+	IdempotencyKey    *string    `json:"idempotency_key" gorm:"size:128;uniqueIndex:uk_email_send_logs_idempotency,priority:1"` // 幂等键（与收件人联合唯一）
+	CreatedAt         time.Time  `json:"created_at" gorm:"index:idx_email_send_logs_created"`
+}
+
+// SendAttemptError 单次发送失败记录
+type SendAttemptError struct {
+	Attempt int       `json:"attempt"`
+	Error   string    `json:"error"`
+	At      time.Time `json:"at"`
 }
 
 // TableName 表名
@@ -31,15 +51,44 @@ func (SendLog) TableName() string {
 	return "email_send_logs"
 }
 
-// MarkSent 标记为已发送
-func (l *SendLog) MarkSent() {
-	now := time.Now()
+// MarkSent 标记为 at 时刻已发送
+func (l *SendLog) MarkSent(at time.Time) {
 	l.Status = SendStatusSent
-	l.SentAt = &now
+	l.SentAt = &at
+	l.NextAttemptAt = nil
 }
 
-// MarkFailed 标记为发送失败
-func (l *SendLog) MarkFailed(errMsg string) {
+// MarkFailed 标记为 at 时刻发送失败
+func (l *SendLog) MarkFailed(errMsg string, at time.Time) {
 	l.Status = SendStatusFailed
 	l.ErrorMessage = errMsg
+	l.NextAttemptAt = nil
+	l.appendError(errMsg, at)
+}
+
+// MarkRetrying 标记为 at 时刻失败、等待重试
+func (l *SendLog) MarkRetrying(errMsg string, at, nextAttemptAt time.Time) {
+	l.Status = SendStatusRetrying
+	l.ErrorMessage = errMsg
+	l.NextAttemptAt = &nextAttemptAt
+	l.appendError(errMsg, at)
+}
+
+// Errors 获取失败记录
+func (l *SendLog) Errors() []SendAttemptError {
+	var history []SendAttemptError
+	if l.ErrorHistory != "" {
+		_ = json.Unmarshal([]byte(l.ErrorHistory), &history)
+	}
+	return history
+}
+
+func (l *SendLog) appendError(errMsg string, at time.Time) {
+	history := append(l.Errors(), SendAttemptError{
+		Attempt: l.Attempts,
+		Error:   errMsg,
+		At:      at,
+	})
+	data, _ := json.Marshal(history)
+	l.ErrorHistory = string(data)
 }
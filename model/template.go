@@ -10,27 +10,64 @@ import (
 type TemplateStatus string
 
 const (
-	TemplateStatusDraft    TemplateStatus = "draft"
-	TemplateStatusEnabled  TemplateStatus = "enabled"
-	TemplateStatusDisabled TemplateStatus = "disabled"
+	TemplateStatusDraft         TemplateStatus = "draft"
+	TemplateStatusPendingReview TemplateStatus = "pending_review"
+	TemplateStatusEnabled       TemplateStatus = "enabled"
+	TemplateStatusDisabled      TemplateStatus = "disabled"
+	TemplateStatusArchived      TemplateStatus = "archived"
 )
 
+// templateTransitions 允许的状态流转
+// draft → pending_review → enabled → disabled → archived；驳回回到 draft，停用后可重新启用或退回草稿修改
+var templateTransitions = map[TemplateStatus][]TemplateStatus{
+	TemplateStatusDraft:         {TemplateStatusPendingReview, TemplateStatusArchived},
+	TemplateStatusPendingReview: {TemplateStatusEnabled, TemplateStatusDraft},
+	TemplateStatusEnabled:       {TemplateStatusDisabled},
+	TemplateStatusDisabled:      {TemplateStatusEnabled, TemplateStatusDraft, TemplateStatusArchived},
+	TemplateStatusArchived:      nil, // 终态
+}
+
+// IsValid 是否为已定义的状态
+func (s TemplateStatus) IsValid() bool {
+	_, ok := templateTransitions[s]
+	return ok
+}
+
+// CanTransitionTo 是否允许流转到目标状态
+func (s TemplateStatus) CanTransitionTo(next TemplateStatus) bool {
+	for _, to := range templateTransitions[s] {
+		if to == next {
+			return true
+		}
+	}
+	return false
+}
+
 // Template 邮件模板
 type Template struct {
-	ID          uint           `json:"id" gorm:"primaryKey"`
-	TriggerCode string         `json:"trigger_code" gorm:"size:100;not null;index:idx_trigger"`
-	Language    string         `json:"language" gorm:"size:10;not null;default:zh-CN"`
-	Name        string         `json:"name" gorm:"size:200;not null"`
-	Subject     string         `json:"subject" gorm:"size:500;not null"`
-	BodyHTML    string         `json:"body_html" gorm:"type:text;not null"`
-	BodyText    string         `json:"body_text" gorm:"type:text"`
-	Status      TemplateStatus `json:"status" gorm:"size:20;not null;default:draft;index:idx_status"`
-	Cc          string         `json:"cc" gorm:"size:1000"`
-	Bcc         string         `json:"bcc" gorm:"size:1000"`
-	ReplyTo     string         `json:"reply_to" gorm:"size:200"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+	ID               uint           `json:"id" gorm:"primaryKey"`
+	TriggerCode      string         `json:"trigger_code" gorm:"size:100;not null;index:idx_email_templates_trigger"`
+	Language         string         `json:"language" gorm:"size:35;not null;default:zh-CN"`
+	Name             string         `json:"name" gorm:"size:200;not null"`
+	Subject          string         `json:"subject" gorm:"size:500;not null"`
+	BodyHTML         string         `json:"body_html" gorm:"type:text;not null"`
+	BodyText         string         `json:"body_text" gorm:"type:text"`
+	Status           TemplateStatus `json:"status" gorm:"size:20;not null;default:draft;index:idx_email_templates_status"`
+	Cc               string         `json:"cc" gorm:"size:1000"`
+	Bcc              string         `json:"bcc" gorm:"size:1000"`
+	ReplyTo          string         `json:"reply_to" gorm:"size:200"`
+	Layout           string         `json:"layout" gorm:"size:100"`                      // 布局名称（为空表示不使用布局）
+	LatestVersion    int            `json:"latest_version" gorm:"not null;default:0"`    // 最新修订版本号（模板字段为最新编辑内容）
+	PublishedVersion int            `json:"published_version" gorm:"not null;default:0"` // 已发布版本号（发送使用，0 表示无版本记录，直接使用模板内容）
+	SubmittedBy      string         `json:"submitted_by" gorm:"size:100"`                // 提交审核人
+	SubmittedAt      *time.Time     `json:"submitted_at"`
+	ReviewedBy       string         `json:"reviewed_by" gorm:"size:100"` // 审核人（通过或驳回）
+	ReviewedAt       *time.Time     `json:"reviewed_at"`
+	RejectReason     string         `json:"reject_reason" gorm:"size:500"`      // 最近一次驳回原因
+	Revision         int            `json:"revision" gorm:"not null;default:0"` // 乐观锁修订号（每次保存递增）
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
 // TableName 表名
@@ -0,0 +1,101 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// TemplateAuditAction 模板审计动作
+type TemplateAuditAction string
+
+const (
+	AuditActionCreate   TemplateAuditAction = "create"
+	AuditActionUpdate   TemplateAuditAction = "update"
+	AuditActionDelete   TemplateAuditAction = "delete"
+	AuditActionSubmit   TemplateAuditAction = "submit"
+	AuditActionApprove  TemplateAuditAction = "approve"
+	AuditActionReject   TemplateAuditAction = "reject"
+	AuditActionPublish  TemplateAuditAction = "publish"
+	AuditActionRollback TemplateAuditAction = "rollback"
+)
+
+// TemplateAudit 模板变更审计记录（只追加，不修改）
+type TemplateAudit struct {
+	ID         uint                `json:"id" gorm:"primaryKey"`
+	TemplateID uint                `json:"template_id" gorm:"not null;index:idx_email_template_audits_template"`
+	Action     TemplateAuditAction `json:"action" gorm:"size:20;not null"`
+	Actor      string              `json:"actor" gorm:"size:100"`
+	Changes    string              `json:"changes" gorm:"type:text"` // JSON 数组，字段变更前后值
+	CreatedAt  time.Time           `json:"created_at"`
+}
+
+// TableName 表名
+func (TemplateAudit) TableName() string {
+	return "email_template_audits"
+}
+
+// FieldChange 单个字段的变更
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// auditSkipFields 不计入差异的字段（主键、乐观锁修订号与自动维护的时间戳）
+var auditSkipFields = map[string]bool{
+	"ID": true, "Revision": true, "CreatedAt": true, "UpdatedAt": true, "DeletedAt": true,
+}
+
+// DiffTemplates 比较模板变更前后的字段差异（before 为 nil 表示新建，after 为 nil 表示删除）
+// 字段名使用 JSON 名称
+func DiffTemplates(before, after *Template) []FieldChange {
+	if before == nil {
+		before = &Template{}
+	}
+	if after == nil {
+		after = &Template{}
+	}
+
+	bv := reflect.ValueOf(before).Elem()
+	av := reflect.ValueOf(after).Elem()
+	typ := bv.Type()
+
+	var changes []FieldChange
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if auditSkipFields[field.Name] {
+			continue
+		}
+		b, a := bv.Field(i).Interface(), av.Field(i).Interface()
+		if reflect.DeepEqual(b, a) {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" {
+			name = field.Name
+		}
+		changes = append(changes, FieldChange{Field: name, Before: b, After: a})
+	}
+	return changes
+}
+
+// SetChanges 写入字段变更
+func (a *TemplateAudit) SetChanges(changes []FieldChange) {
+	if len(changes) == 0 {
+		a.Changes = ""
+		return
+	}
+	data, _ := json.Marshal(changes)
+	a.Changes = string(data)
+}
+
+// FieldChanges 获取字段变更
+func (a *TemplateAudit) FieldChanges() []FieldChange {
+	var changes []FieldChange
+	if a.Changes != "" {
+		_ = json.Unmarshal([]byte(a.Changes), &changes)
+	}
+	return changes
+}
@@ -0,0 +1,64 @@
+package model
+
+import "time"
+
+// TemplateVersion 模板内容修订记录（只追加，不修改）
+type TemplateVersion struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	TemplateID uint      `json:"template_id" gorm:"not null;uniqueIndex:uk_email_template_versions_version,priority:1"`
+	Version    int       `json:"version" gorm:"not null;uniqueIndex:uk_email_template_versions_version,priority:2"`
+	Subject    string    `json:"subject" gorm:"size:500;not null"`
+	BodyHTML   string    `json:"body_html" gorm:"type:text;not null"`
+	BodyText   string    `json:"body_text" gorm:"type:text"`
+	Cc         string    `json:"cc" gorm:"size:1000"`
+	Bcc        string    `json:"bcc" gorm:"size:1000"`
+	ReplyTo    string    `json:"reply_to" gorm:"size:200"`
+	Layout     string    `json:"layout" gorm:"size:100"`
+	Author     string    `json:"author" gorm:"size:100"`
+	ChangeNote string    `json:"change_note" gorm:"size:500"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TableName 表名
+func (TemplateVersion) TableName() string {
+	return "email_template_versions"
+}
+
+// NewTemplateVersion 以模板当前内容创建修订记录
+func NewTemplateVersion(t *Template, version int, author, changeNote string) *TemplateVersion {
+	return &TemplateVersion{
+		TemplateID: t.ID,
+		Version:    version,
+		Subject:    t.Subject,
+		BodyHTML:   t.BodyHTML,
+		BodyText:   t.BodyText,
+		Cc:         t.Cc,
+		Bcc:        t.Bcc,
+		ReplyTo:    t.ReplyTo,
+		Layout:     t.Layout,
+		Author:     author,
+		ChangeNote: changeNote,
+	}
+}
+
+// ApplyTo 将修订内容写入模板
+func (v *TemplateVersion) ApplyTo(t *Template) {
+	t.Subject = v.Subject
+	t.BodyHTML = v.BodyHTML
+	t.BodyText = v.BodyText
+	t.Cc = v.Cc
+	t.Bcc = v.Bcc
+	t.ReplyTo = v.ReplyTo
+	t.Layout = v.Layout
+}
+
+// SameContent 修订内容是否与模板一致
+func (v *TemplateVersion) SameContent(t *Template) bool {
+	return v.Subject == t.Subject &&
+		v.BodyHTML == t.BodyHTML &&
+		v.BodyText == t.BodyText &&
+		v.Cc == t.Cc &&
+		v.Bcc == t.Bcc &&
+		v.ReplyTo == t.ReplyTo &&
+		v.Layout == t.Layout
+}
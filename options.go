@@ -0,0 +1,173 @@
+package email_notification
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Clock 时钟（便于测试中固定时间）
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc 函数形式的时钟
+type ClockFunc func() time.Time
+
+// Now 当前时间
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// systemClock 系统时钟
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Option 服务配置项
+type Option func(s *Service)
+
+// WithTemplateRepository 使用自定义模板仓储
+func WithTemplateRepository(repo TemplateRepository) Option {
+	return func(s *Service) {
+		s.templateRepo = repo
+	}
+}
+
+// WithSendLogRepository 使用自定义发送日志仓储
+func WithSendLogRepository(repo SendLogRepository) Option {
+	return func(s *Service) {
+		s.logRepo = repo
+	}
+}
+
+// WithSendJobRepository 使用自定义发送队列仓储
+func WithSendJobRepository(repo SendJobRepository) Option {
+	return func(s *Service) {
+		s.jobRepo = repo
+	}
+}
+
+// WithTemplateVersionRepository 使用自定义模板版本仓储
+func WithTemplateVersionRepository(repo TemplateVersionRepository) Option {
+	return func(s *Service) {
+		s.versionRepo = repo
+	}
+}
+
+// WithTemplateAuditRepository 使用自定义模板审计仓储
+func WithTemplateAuditRepository(repo TemplateAuditRepository) Option {
+	return func(s *Service) {
+		s.auditRepo = repo
+	}
+}
+
+// WithPartialRepository 使用自定义布局与片段仓储
+func WithPartialRepository(repo PartialRepository) Option {
+	return func(s *Service) {
+		s.partialRepo = repo
+	}
+}
+
+// WithMemoryRepositories 全部使用内存仓储（测试与嵌入式部署）
+func WithMemoryRepositories() Option {
+	return func(s *Service) {
+		s.templateRepo = NewMemoryTemplateRepository()
+		s.versionRepo = NewMemoryTemplateVersionRepository()
+		s.auditRepo = NewMemoryTemplateAuditRepository()
+		s.partialRepo = NewMemoryPartialRepository()
+		s.logRepo = NewMemorySendLogRepository()
+		s.jobRepo = NewMemorySendJobRepository()
+	}
+}
+
+// WithDefaultLanguage 设置默认语言（默认 zh-CN）
+// 未指定语言时使用，也是语言回退链的终点
+func WithDefaultLanguage(language string) Option {
+	return func(s *Service) {
+		if normalized, err := NormalizeLanguage(language); err == nil && normalized != "" {
+			s.defaultLanguage = normalized
+		}
+	}
+}
+
+// WithLanguageFallback 设置语言回退链
+// 查找模板与布局时依次尝试：请求语言 → 逐级截断（pt-BR → pt）→ languages → 默认语言
+// 如 WithLanguageFallback("en") 时 pt-BR 的回退链为 pt-BR → pt → en → zh-CN
+func WithLanguageFallback(languages ...string) Option {
+	return func(s *Service) {
+		s.languageFallbacks = s.languageFallbacks[:0]
+		for _, lang := range languages {
+			if normalized, err := NormalizeLanguage(lang); err == nil && normalized != "" {
+				s.languageFallbacks = append(s.languageFallbacks, normalized)
+			}
+		}
+	}
+}
+
+// WithEngine 使用自定义模板引擎
+func WithEngine(engine *TemplateEngine) Option {
+	return func(s *Service) {
+		s.engine = engine
+	}
+}
+
+// WithSender 使用自定义邮件发送器
+func WithSender(sender Sender) Option {
+	return func(s *Service) {
+		s.sender = sender
+	}
+}
+
+// WithClock 使用自定义时钟
+func WithClock(clock Clock) Option {
+	return func(s *Service) {
+		s.clock = clock
+	}
+}
+
+// WithLogger 使用自定义日志
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Service) {
+		s.logger = logger
+	}
+}
+
+// WithRetryPolicy 设置默认重试策略
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(s *Service) {
+		s.retryPolicy = policy
+	}
+}
+
+// WithActorResolver 自定义操作人解析（默认读取 WithActor 设置的操作人）
+// 用于审计、版本作者与审核人记录，可接入应用的认证上下文
+func WithActorResolver(resolver func(ctx context.Context) string) Option {
+	return func(s *Service) {
+		s.actorResolver = resolver
+	}
+}
+
+// WithIdempotencyWindow 设置幂等键有效期（默认 DefaultIdempotencyWindow，<= 0 表示永久有效）
+// 超出有效期后以相同幂等键发送视为新请求
+func WithIdempotencyWindow(window time.Duration) Option {
+	return func(s *Service) {
+		s.idempotencyWindow = window
+	}
+}
+
+// templateCacheConfig 模板与片段缓存配置
+type templateCacheConfig struct {
+	size int
+	ttl  time.Duration
+}
+
+// WithTemplateCache 启用发送热路径缓存：GetActiveTemplate 读穿透缓存与布局/片段缓存
+// 本进程内的模板与片段写操作会立即失效缓存；多实例部署时其他实例的缓存在 ttl 后过期
+func WithTemplateCache(size int, ttl time.Duration) Option {
+	return func(s *Service) {
+		s.templateCache = &templateCacheConfig{size: size, ttl: ttl}
+	}
+}
@@ -0,0 +1,40 @@
+package email_notification
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewService_Options(t *testing.T) {
+	fixed := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	sender := NewRecordingSender()
+	engine := NewTemplateEngine()
+
+	svc := NewService(nil, nil, NewTriggerRegistry(), map[string]any{"AppName": "Yogan"},
+		WithClock(ClockFunc(func() time.Time { return fixed })),
+		WithSender(sender),
+		WithEngine(engine),
+		WithRetryPolicy(NoRetry),
+	)
+
+	if svc.sender != sender {
+		t.Error("expected custom sender")
+	}
+	if svc.engine != engine {
+		t.Error("expected custom engine")
+	}
+	if svc.retryPolicy != NoRetry {
+		t.Error("expected custom retry policy")
+	}
+	if svc.templateRepo == nil || svc.logRepo == nil || svc.jobRepo == nil {
+		t.Error("expected default repositories")
+	}
+
+	params := svc.mergeParams(map[string]any{"UserName": "张三"})
+	if params["CurrentYear"] != 2030 {
+		t.Errorf("expected CurrentYear 2030 from clock, got %v", params["CurrentYear"])
+	}
+	if params["AppName"] != "Yogan" {
+		t.Error("expected common params to be merged")
+	}
+}
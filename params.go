@@ -0,0 +1,81 @@
+package email_notification
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// paramTimeKey 持久化参数中 time.Time 的标记键：{"$time": "<RFC3339Nano>"}
+const paramTimeKey = "$time"
+
+// persistParams 返回用于 JSON 持久化（发送日志、队列）的参数副本
+// time.Time 记为带标记的对象，还原时恢复为 time.Time，保证异步发送与同步发送渲染一致
+func persistParams(params map[string]any) map[string]any {
+	result := make(map[string]any, len(params))
+	for k, v := range params {
+		result[k] = persistValue(v)
+	}
+	return result
+}
+
+func persistValue(value any) any {
+	switch v := value.(type) {
+	case time.Time:
+		return map[string]any{paramTimeKey: v.Format(time.RFC3339Nano)}
+	case *time.Time:
+		if v != nil {
+			return map[string]any{paramTimeKey: v.Format(time.RFC3339Nano)}
+		}
+	case map[string]any:
+		return persistParams(v)
+	case []any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = persistValue(item)
+		}
+		return items
+	}
+	return value
+}
+
+// restoreParams 还原持久化参数的类型：json.Number 还原为 int64（整数）或 float64，时间标记还原为 time.Time
+// 须配合 decodeJSON 解码，避免大整数经 float64 丢失精度
+func restoreParams(params map[string]any) map[string]any {
+	for k, v := range params {
+		params[k] = restoreValue(v)
+	}
+	return params
+}
+
+func restoreValue(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case map[string]any:
+		if raw, ok := v[paramTimeKey].(string); ok && len(v) == 1 {
+			if t, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+				return t
+			}
+		}
+		return restoreParams(v)
+	case []any:
+		for i, item := range v {
+			v[i] = restoreValue(item)
+		}
+	}
+	return value
+}
+
+// decodeJSON 解码 JSON，数字保留为 json.Number
+func decodeJSON(data string, v any) error {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
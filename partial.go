@@ -0,0 +1,161 @@
+package email_notification
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// rootTemplateName 渲染时正文模板的名称（片段通过名称引用，不应与之重名）
+const rootTemplateName = "email"
+
+// ResolvePartials 解析模板直接或间接引用的片段，按依赖顺序返回片段名称
+// 引用不存在的片段返回 ErrPartialNotFound，循环引用返回 ErrPartialCycle
+func ResolvePartials(templateStr string, partials map[string]string) ([]string, error) {
+	w := newPartialWalker(partials, false)
+	if err := w.walkSource(templateStr, nil); err != nil {
+		return nil, err
+	}
+	return w.order, nil
+}
+
+// digestPartials 计算片段集合的内容摘要
+func digestPartials(sets ...map[string]string) string {
+	h := sha256.New()
+	for _, partials := range sets {
+		names := make([]string, 0, len(partials))
+		for name := range partials {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			h.Write([]byte(name))
+			h.Write([]byte{0})
+			h.Write([]byte(partials[name]))
+			h.Write([]byte{0})
+		}
+		h.Write([]byte{1})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// CheckPartialCycle 检查指定片段是否处于循环引用中（忽略尚未创建的片段）
+func CheckPartialCycle(name string, partials map[string]string) error {
+	return newPartialWalker(partials, true).visit(name, nil)
+}
+
+const (
+	partialVisiting = iota + 1
+	partialVisited
+)
+
+// partialWalker 深度优先遍历片段引用图
+type partialWalker struct {
+	partials     map[string]string
+	allowMissing bool
+	state        map[string]int
+	order        []string
+}
+
+func newPartialWalker(partials map[string]string, allowMissing bool) *partialWalker {
+	return &partialWalker{partials: partials, allowMissing: allowMissing, state: make(map[string]int)}
+}
+
+func (w *partialWalker) walkSource(src string, path []string) error {
+	refs, err := templateRefs(src)
+	if err != nil {
+		return ErrTemplateRender.Wrap(err)
+	}
+	for _, ref := range refs {
+		if err := w.visit(ref, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *partialWalker) visit(name string, path []string) error {
+	switch w.state[name] {
+	case partialVisiting:
+		cycle := append(path[indexOf(path, name):], name)
+		return ErrPartialCycle.WithMsg("片段循环引用: " + strings.Join(cycle, " → "))
+	case partialVisited:
+		return nil
+	}
+
+	src, ok := w.partials[name]
+	if !ok {
+		if w.allowMissing {
+			w.state[name] = partialVisited
+			return nil
+		}
+		return ErrPartialNotFound.WithMsg("片段不存在: " + name)
+	}
+
+	w.state[name] = partialVisiting
+	next := append(append([]string(nil), path...), name)
+	if err := w.walkSource(src, next); err != nil {
+		return err
+	}
+	w.state[name] = partialVisited
+	w.order = append(w.order, name)
+	return nil
+}
+
+func indexOf(items []string, target string) int {
+	for i, item := range items {
+		if item == target {
+			return i
+		}
+	}
+	return 0
+}
+
+// templateRefs 解析模板中 {{template "name"}} 引用的外部模板名称（排除模板内 define 的名称）
+func templateRefs(src string) ([]string, error) {
+	trees := make(map[string]*parse.Tree)
+	t := parse.New(rootTemplateName)
+	t.Mode = parse.SkipFuncCheck
+	if _, err := t.Parse(src, "", "", trees); err != nil {
+		return nil, err
+	}
+
+	refs := make(map[string]bool)
+	for _, tree := range trees {
+		collectTemplateRefs(tree.Root, refs)
+	}
+
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		if _, defined := trees[name]; !defined {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func collectTemplateRefs(node parse.Node, refs map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectTemplateRefs(child, refs)
+		}
+	case *parse.IfNode:
+		collectTemplateRefs(n.List, refs)
+		collectTemplateRefs(n.ElseList, refs)
+	case *parse.RangeNode:
+		collectTemplateRefs(n.List, refs)
+		collectTemplateRefs(n.ElseList, refs)
+	case *parse.WithNode:
+		collectTemplateRefs(n.List, refs)
+		collectTemplateRefs(n.ElseList, refs)
+	case *parse.TemplateNode:
+		refs[n.Name] = true
+	}
+}
@@ -2,6 +2,7 @@ package email_notification
 
 import (
 	"context"
+	"time"
 
 	"github.com/KOMKZ/go-yogan-domain-email-notification/model"
 )
@@ -15,6 +16,12 @@ type TemplateFilter struct {
 	PageSize    int
 }
 
+// PartialFilter 布局与片段筛选条件
+type PartialFilter struct {
+	Language string
+	Kind     model.PartialKind
+}
+
 // LogFilter 日志筛选条件
 type LogFilter struct {
 	TriggerCode string
@@ -39,7 +46,7 @@ type TemplateRepository interface {
 	// Create 创建模板
 	Create(ctx context.Context, template *model.Template) error
 
-	// Update 更新模板
+	// Update 更新模板（乐观锁：template.Revision 须与存储中一致，否则返回 ErrTemplateConflict；成功后修订号加一）
 	Update(ctx context.Context, template *model.Template) error
 
 	// Delete 删除模板（软删除）
@@ -60,7 +67,7 @@ type TemplateRepository interface {
 
 // SendLogRepository 发送日志仓储接口
 type SendLogRepository interface {
-	// Create 创建日志
+	// Create 创建日志（幂等键与收件人重复时返回 ErrIdempotencyKeyUsed）
 	Create(ctx context.Context, log *model.SendLog) error
 
 	// Update 更新日志
@@ -71,4 +78,79 @@ type SendLogRepository interface {
 
 	// List 列表查询
 	List(ctx context.Context, filter LogFilter) (*PageResult[model.SendLog], error)
+
+	// ListAfterID 游标查询：按 ID 升序返回 ID 大于 afterID 的日志（忽略 filter 的分页参数）
+	ListAfterID(ctx context.Context, filter LogFilter, afterID uint, limit int) ([]model.SendLog, error)
+
+	// ExistsNewer 同一触发点、收件人下是否存在 ID 大于 afterID 的已发送日志
+	ExistsNewer(ctx context.Context, triggerCode, recipient string, afterID uint) (bool, error)
+
+	// ExistsResend 是否存在以 parentID 为原日志的重发日志
+	ExistsResend(ctx context.Context, parentID uint) (bool, error)
+
+	// ListByIdempotencyKey 按幂等键查询日志（按 ID 升序，每个收件人一条）
+	ListByIdempotencyKey(ctx context.Context, key string) ([]model.SendLog, error)
+}
+
+// SendJobRepository 异步发送队列仓储接口
+type SendJobRepository interface {
+	// Create 入队
+	Create(ctx context.Context, job *model.SendJob) error
+
+	// Update 更新任务
+	Update(ctx context.Context, job *model.SendJob) error
+
+	// Claim 领取 now 时刻可执行的任务（排队中且已到期，或租约已过期），并加租约
+	Claim(ctx context.Context, workerID string, limit int, now time.Time, lease time.Duration) ([]*model.SendJob, error)
+
+	// Release 写回已领取任务的结果（完成、失败或重新入队）
+	// 仅当任务仍由 lockedBy 以租约 lockedUntil 持有时写入；租约过期后已被其他 Worker 领取时返回 false
+	Release(ctx context.Context, job *model.SendJob, lockedBy string, lockedUntil time.Time) (bool, error)
+}
+
+// leaseUntil 计算租约到期时间，取整到秒，保证各数据库的时间精度下 Release 的租约条件可精确匹配
+func leaseUntil(now time.Time, lease time.Duration) time.Time {
+	return now.Add(lease).Truncate(time.Second)
+}
+
+// TemplateVersionRepository 模板版本仓储接口
+type TemplateVersionRepository interface {
+	// Create 创建修订记录
+	Create(ctx context.Context, version *model.TemplateVersion) error
+
+	// Get 获取指定模板的指定版本
+	Get(ctx context.Context, templateID uint, version int) (*model.TemplateVersion, error)
+
+	// ListByTemplate 获取模板的全部版本（按版本号倒序）
+	ListByTemplate(ctx context.Context, templateID uint) ([]model.TemplateVersion, error)
+}
+
+// TemplateAuditRepository 模板审计仓储接口
+type TemplateAuditRepository interface {
+	// Create 写入审计记录
+	Create(ctx context.Context, audit *model.TemplateAudit) error
+
+	// ListByTemplate 获取模板的审计记录（按时间倒序）
+	ListByTemplate(ctx context.Context, templateID uint) ([]model.TemplateAudit, error)
+}
+
+// PartialRepository 布局与片段仓储接口
+type PartialRepository interface {
+	// Create 创建（名称和语言重复时返回 ErrPartialExists）
+	Create(ctx context.Context, partial *model.Partial) error
+
+	// Update 更新
+	Update(ctx context.Context, partial *model.Partial) error
+
+	// Delete 删除
+	Delete(ctx context.Context, id uint) error
+
+	// GetByID 根据 ID 获取
+	GetByID(ctx context.Context, id uint) (*model.Partial, error)
+
+	// GetByName 根据名称和语言获取
+	GetByName(ctx context.Context, name, language string) (*model.Partial, error)
+
+	// List 列表（按名称、语言排序）
+	List(ctx context.Context, filter PartialFilter) ([]model.Partial, error)
 }
@@ -0,0 +1,80 @@
+package email_notification
+
+import (
+	"context"
+	"time"
+
+	"github.com/KOMKZ/go-yogan-domain-email-notification/model"
+)
+
+// ============ Template Repository 读穿透缓存 ============
+
+// cachingTemplateRepository 缓存 GetActiveTemplate 结果（发送热路径），写操作清空缓存
+// 缓存仅在当前进程内失效，多实例部署时其他实例依赖 ttl 过期
+type cachingTemplateRepository struct {
+	next  TemplateRepository
+	cache *lruCache[string, model.Template]
+	inTx  bool // 绑定事务的副本：读取不经过缓存，避免缓存未提交的数据
+}
+
+// NewCachingTemplateRepository 为模板仓储增加 GetActiveTemplate 读穿透缓存
+// size 为最大缓存条目数，ttl 为过期时间（0 表示仅依赖写操作失效）
+func NewCachingTemplateRepository(next TemplateRepository, size int, ttl time.Duration) TemplateRepository {
+	return &cachingTemplateRepository{next: next, cache: newLRUCache[string, model.Template](size, ttl)}
+}
+
+// withNext 返回共享缓存、替换底层仓储的副本（用于绑定事务）
+// 事务内的写操作仍即时清空缓存，但提交前其他请求可能重新缓存旧数据，须在提交后再调用 Purge
+func (r *cachingTemplateRepository) withNext(next TemplateRepository) *cachingTemplateRepository {
+	return &cachingTemplateRepository{next: next, cache: r.cache, inTx: true}
+}
+
+// Purge 清空缓存
+func (r *cachingTemplateRepository) Purge() {
+	r.cache.Purge()
+}
+
+func (r *cachingTemplateRepository) Create(ctx context.Context, template *model.Template) error {
+	defer r.cache.Purge()
+	return r.next.Create(ctx, template)
+}
+
+func (r *cachingTemplateRepository) Update(ctx context.Context, template *model.Template) error {
+	defer r.cache.Purge()
+	return r.next.Update(ctx, template)
+}
+
+func (r *cachingTemplateRepository) Delete(ctx context.Context, id uint) error {
+	defer r.cache.Purge()
+	return r.next.Delete(ctx, id)
+}
+
+func (r *cachingTemplateRepository) GetByID(ctx context.Context, id uint) (*model.Template, error) {
+	return r.next.GetByID(ctx, id)
+}
+
+func (r *cachingTemplateRepository) GetActiveTemplate(ctx context.Context, triggerCode, language string) (*model.Template, error) {
+	if r.inTx {
+		return r.next.GetActiveTemplate(ctx, triggerCode, language)
+	}
+
+	key := triggerCode + "\x00" + language
+	if template, ok := r.cache.Get(key); ok {
+		return &template, nil
+	}
+
+	template, err := r.next.GetActiveTemplate(ctx, triggerCode, language)
+	if err != nil {
+		return nil, err
+	}
+	r.cache.Add(key, *template)
+	return template, nil
+}
+
+func (r *cachingTemplateRepository) List(ctx context.Context, filter TemplateFilter) (*PageResult[model.Template], error) {
+	return r.next.List(ctx, filter)
+}
+
+func (r *cachingTemplateRepository) ExistsByTriggerAndLanguage(ctx context.Context, triggerCode, language string, excludeID uint) (bool, error) {
+	return r.next.ExistsByTriggerAndLanguage(ctx, triggerCode, language, excludeID)
+}
@@ -0,0 +1,629 @@
+package email_notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KOMKZ/go-yogan-domain-email-notification/model"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 仓储一致性测试：GORM（SQLite）与内存实现必须通过同一套用例
+
+func newTestDB(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", strings.NewReplacer("/", "_", " ", "_").Replace(t.Name()))
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := Migrate(context.Background(), db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+func TestTemplateRepository_Conformance(t *testing.T) {
+	t.Run("gorm", func(t *testing.T) {
+		testTemplateRepository(t, func(t *testing.T) TemplateRepository { return NewGormTemplateRepository(newTestDB(t)) })
+	})
+	t.Run("memory", func(t *testing.T) {
+		testTemplateRepository(t, func(t *testing.T) TemplateRepository { return NewMemoryTemplateRepository() })
+	})
+}
+
+func TestSendLogRepository_Conformance(t *testing.T) {
+	t.Run("gorm", func(t *testing.T) {
+		testSendLogRepository(t, func(t *testing.T) SendLogRepository { return NewGormSendLogRepository(newTestDB(t)) })
+	})
+	t.Run("memory", func(t *testing.T) {
+		testSendLogRepository(t, func(t *testing.T) SendLogRepository { return NewMemorySendLogRepository() })
+	})
+}
+
+func TestSendJobRepository_Conformance(t *testing.T) {
+	t.Run("gorm", func(t *testing.T) {
+		testSendJobRepository(t, func(t *testing.T) SendJobRepository { return NewGormSendJobRepository(newTestDB(t)) })
+	})
+	t.Run("memory", func(t *testing.T) {
+		testSendJobRepository(t, func(t *testing.T) SendJobRepository { return NewMemorySendJobRepository() })
+	})
+}
+
+func testTemplateRepository(t *testing.T, newRepo func(t *testing.T) TemplateRepository) {
+	ctx := context.Background()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local)
+
+	newTemplate := func(trigger, language string, status model.TemplateStatus, offset int) *model.Template {
+		return &model.Template{
+			TriggerCode: trigger,
+			Language:    language,
+			Name:        trigger + " " + language,
+			Subject:     "Hello {{.UserName}}",
+			BodyHTML:    "<p>Hello {{.UserName}}</p>",
+			Status:      status,
+			CreatedAt:   base.Add(time.Duration(offset) * time.Hour),
+		}
+	}
+
+	t.Run("create and get", func(t *testing.T) {
+		repo := newRepo(t)
+		tpl := newTemplate("user:registered", "zh-CN", model.TemplateStatusDraft, 0)
+		if err := repo.Create(ctx, tpl); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if tpl.ID == 0 {
+			t.Fatal("expected ID to be assigned")
+		}
+
+		got, err := repo.GetByID(ctx, tpl.ID)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		if got.Name != tpl.Name || got.Subject != tpl.Subject {
+			t.Errorf("unexpected template: %+v", got)
+		}
+
+		if _, err := repo.GetByID(ctx, tpl.ID+100); !errors.Is(err, ErrTemplateNotFound) {
+			t.Errorf("expected ErrTemplateNotFound, got %v", err)
+		}
+	})
+
+	t.Run("active template", func(t *testing.T) {
+		repo := newRepo(t)
+		repo.Create(ctx, newTemplate("user:registered", "zh-CN", model.TemplateStatusDraft, 0))
+		enabled := newTemplate("user:registered", "en-US", model.TemplateStatusEnabled, 1)
+		repo.Create(ctx, enabled)
+
+		got, err := repo.GetActiveTemplate(ctx, "user:registered", "en-US")
+		if err != nil {
+			t.Fatalf("get active: %v", err)
+		}
+		if got.ID != enabled.ID {
+			t.Errorf("expected template %d, got %d", enabled.ID, got.ID)
+		}
+
+		if _, err := repo.GetActiveTemplate(ctx, "user:registered", "zh-CN"); !errors.Is(err, ErrTemplateNotFound) {
+			t.Errorf("expected draft template to be ignored, got %v", err)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		repo := newRepo(t)
+		tpl := newTemplate("user:registered", "zh-CN", model.TemplateStatusDraft, 0)
+		repo.Create(ctx, tpl)
+
+		tpl.Subject = "Welcome"
+		tpl.Status = model.TemplateStatusEnabled
+		if err := repo.Update(ctx, tpl); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+
+		got, _ := repo.GetByID(ctx, tpl.ID)
+		if got.Subject != "Welcome" || got.Status != model.TemplateStatusEnabled {
+			t.Errorf("expected updated template, got %+v", got)
+		}
+	})
+
+	t.Run("update conflict", func(t *testing.T) {
+		repo := newRepo(t)
+		tpl := newTemplate("user:registered", "zh-CN", model.TemplateStatusDraft, 0)
+		repo.Create(ctx, tpl)
+
+		first, _ := repo.GetByID(ctx, tpl.ID)
+		second, _ := repo.GetByID(ctx, tpl.ID)
+
+		first.Subject = "First"
+		if err := repo.Update(ctx, first); err != nil {
+			t.Fatalf("first update: %v", err)
+		}
+		if first.Revision != tpl.Revision+1 {
+			t.Errorf("expected revision %d, got %d", tpl.Revision+1, first.Revision)
+		}
+
+		second.Subject = "Second"
+		if err := repo.Update(ctx, second); !errors.Is(err, ErrTemplateConflict) {
+			t.Fatalf("expected ErrTemplateConflict, got %v", err)
+		}
+		if second.Revision != tpl.Revision {
+			t.Errorf("expected revision to be left unchanged on conflict, got %d", second.Revision)
+		}
+
+		got, _ := repo.GetByID(ctx, tpl.ID)
+		if got.Subject != "First" || got.Revision != first.Revision {
+			t.Errorf("expected first update to win, got %+v", got)
+		}
+
+		missing := *got
+		missing.ID = got.ID + 100
+		if err := repo.Update(ctx, &missing); !errors.Is(err, ErrTemplateNotFound) {
+			t.Errorf("expected ErrTemplateNotFound, got %v", err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		repo := newRepo(t)
+		tpl := newTemplate("user:registered", "zh-CN", model.TemplateStatusEnabled, 0)
+		repo.Create(ctx, tpl)
+
+		if err := repo.Delete(ctx, tpl.ID); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		if _, err := repo.GetByID(ctx, tpl.ID); !errors.Is(err, ErrTemplateNotFound) {
+			t.Errorf("expected ErrTemplateNotFound after delete, got %v", err)
+		}
+		exists, _ := repo.ExistsByTriggerAndLanguage(ctx, "user:registered", "zh-CN", 0)
+		if exists {
+			t.Error("expected deleted template not to exist")
+		}
+	})
+
+	t.Run("exists", func(t *testing.T) {
+		repo := newRepo(t)
+		tpl := newTemplate("user:registered", "zh-CN", model.TemplateStatusDraft, 0)
+		repo.Create(ctx, tpl)
+
+		if exists, _ := repo.ExistsByTriggerAndLanguage(ctx, "user:registered", "zh-CN", 0); !exists {
+			t.Error("expected template to exist")
+		}
+		if exists, _ := repo.ExistsByTriggerAndLanguage(ctx, "user:registered", "zh-CN", tpl.ID); exists {
+			t.Error("expected excluded template not to count")
+		}
+		if exists, _ := repo.ExistsByTriggerAndLanguage(ctx, "user:registered", "en-US", 0); exists {
+			t.Error("expected other language not to exist")
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		repo := newRepo(t)
+		repo.Create(ctx, newTemplate("a", "zh-CN", model.TemplateStatusEnabled, 0))
+		repo.Create(ctx, newTemplate("a", "en-US", model.TemplateStatusDraft, 1))
+		repo.Create(ctx, newTemplate("a", "ja-JP", model.TemplateStatusEnabled, 2))
+		repo.Create(ctx, newTemplate("b", "zh-CN", model.TemplateStatusEnabled, 3))
+		repo.Create(ctx, newTemplate("b", "en-US", model.TemplateStatusDisabled, 4))
+
+		page, err := repo.List(ctx, TemplateFilter{TriggerCode: "a", PageSize: 2})
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		if page.Total != 3 || page.TotalPages != 2 || page.Page != 1 || len(page.Items) != 2 {
+			t.Fatalf("unexpected page: total=%d pages=%d page=%d items=%d", page.Total, page.TotalPages, page.Page, len(page.Items))
+		}
+		if page.Items[0].Language != "ja-JP" || page.Items[1].Language != "en-US" {
+			t.Errorf("expected created_at DESC order, got %s, %s", page.Items[0].Language, page.Items[1].Language)
+		}
+
+		page, _ = repo.List(ctx, TemplateFilter{TriggerCode: "a", Page: 2, PageSize: 2})
+		if len(page.Items) != 1 || page.Items[0].Language != "zh-CN" {
+			t.Errorf("unexpected second page: %+v", page.Items)
+		}
+
+		page, _ = repo.List(ctx, TemplateFilter{Status: model.TemplateStatusEnabled})
+		if page.Total != 3 || page.PageSize != 20 {
+			t.Errorf("expected 3 enabled templates with default page size, got total=%d size=%d", page.Total, page.PageSize)
+		}
+
+		page, _ = repo.List(ctx, TemplateFilter{Language: "en-US"})
+		if page.Total != 2 {
+			t.Errorf("expected 2 en-US templates, got %d", page.Total)
+		}
+	})
+}
+
+func testSendLogRepository(t *testing.T, newRepo func(t *testing.T) SendLogRepository) {
+	ctx := context.Background()
+
+	newLog := func(trigger string, status model.SendStatus, createdAt time.Time) *model.SendLog {
+		return &model.SendLog{
+			TriggerCode: trigger,
+			Language:    "zh-CN",
+			Recipient:   "user@example.com",
+			Subject:     "Hello",
+			Params:      "{}",
+			Status:      status,
+			CreatedAt:   createdAt,
+		}
+	}
+
+	t.Run("create, get and update", func(t *testing.T) {
+		repo := newRepo(t)
+		log := newLog("user:registered", model.SendStatusPending, time.Now())
+		if err := repo.Create(ctx, log); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if log.ID == 0 {
+			t.Fatal("expected ID to be assigned")
+		}
+
+		log.MarkSent(time.Now())
+		if err := repo.Update(ctx, log); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+
+		got, err := repo.GetByID(ctx, log.ID)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		if got.Status != model.SendStatusSent || got.SentAt == nil {
+			t.Errorf("expected sent log, got %+v", got)
+		}
+
+		if _, err := repo.GetByID(ctx, log.ID+100); !errors.Is(err, ErrSendLogNotFound) {
+			t.Errorf("expected ErrSendLogNotFound, got %v", err)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		repo := newRepo(t)
+		day := func(d int) time.Time { return time.Date(2026, 1, d, 12, 0, 0, 0, time.Local) }
+		repo.Create(ctx, newLog("a", model.SendStatusSent, day(1)))
+		repo.Create(ctx, newLog("a", model.SendStatusFailed, day(3)))
+		repo.Create(ctx, newLog("a", model.SendStatusFailed, day(5)))
+		repo.Create(ctx, newLog("b", model.SendStatusFailed, day(7)))
+
+		page, err := repo.List(ctx, LogFilter{Status: model.SendStatusFailed, PageSize: 2})
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		if page.Total != 3 || page.TotalPages != 2 || len(page.Items) != 2 {
+			t.Fatalf("unexpected page: total=%d pages=%d items=%d", page.Total, page.TotalPages, len(page.Items))
+		}
+		if page.Items[0].TriggerCode != "b" {
+			t.Errorf("expected created_at DESC order, got %s first", page.Items[0].TriggerCode)
+		}
+
+		page, _ = repo.List(ctx, LogFilter{TriggerCode: "a", StartTime: "2026-01-02", EndTime: "2026-01-06"})
+		if page.Total != 2 {
+			t.Errorf("expected 2 logs in time range, got %d", page.Total)
+		}
+	})
+
+	t.Run("cursor", func(t *testing.T) {
+		repo := newRepo(t)
+		day := func(d int) time.Time { return time.Date(2026, 1, d, 12, 0, 0, 0, time.Local) }
+		first := newLog("a", model.SendStatusFailed, day(5))
+		repo.Create(ctx, first)
+		repo.Create(ctx, newLog("a", model.SendStatusSent, day(1)))
+		repo.Create(ctx, newLog("a", model.SendStatusFailed, day(3)))
+		repo.Create(ctx, newLog("b", model.SendStatusFailed, day(7)))
+
+		filter := LogFilter{Status: model.SendStatusFailed}
+		batch, err := repo.ListAfterID(ctx, filter, 0, 2)
+		if err != nil {
+			t.Fatalf("list after id: %v", err)
+		}
+		if len(batch) != 2 || batch[0].ID != first.ID || batch[1].ID <= batch[0].ID {
+			t.Fatalf("expected first 2 failed logs in ID order, got %+v", batch)
+		}
+		batch, _ = repo.ListAfterID(ctx, filter, batch[1].ID, 2)
+		if len(batch) != 1 || batch[0].TriggerCode != "b" {
+			t.Fatalf("expected last failed log, got %+v", batch)
+		}
+		if batch, _ = repo.ListAfterID(ctx, filter, batch[0].ID, 2); len(batch) != 0 {
+			t.Errorf("expected cursor exhausted, got %d logs", len(batch))
+		}
+
+		if ok, err := repo.ExistsNewer(ctx, "a", "user@example.com", first.ID); err != nil || !ok {
+			t.Errorf("expected newer log for trigger a, got %v, %v", ok, err)
+		}
+		if ok, _ := repo.ExistsNewer(ctx, "b", "user@example.com", first.ID+3); ok {
+			t.Error("expected no newer log for trigger b")
+		}
+		if ok, _ := repo.ExistsNewer(ctx, "a", "other@example.com", 0); ok {
+			t.Error("expected no log for other recipient")
+		}
+		if ok, _ := repo.ExistsNewer(ctx, "a", "user@example.com", first.ID+1); ok {
+			t.Error("expected newer failed log to be ignored")
+		}
+
+		if ok, err := repo.ExistsResend(ctx, first.ID); err != nil || ok {
+			t.Errorf("expected no resend yet, got %v, %v", ok, err)
+		}
+		child := newLog("a", model.SendStatusQueued, day(8))
+		child.ParentLogID = &first.ID
+		repo.Create(ctx, child)
+		if ok, err := repo.ExistsResend(ctx, first.ID); err != nil || !ok {
+			t.Errorf("expected resend of first log, got %v, %v", ok, err)
+		}
+	})
+
+	t.Run("idempotency key", func(t *testing.T) {
+		repo := newRepo(t)
+		key := "order-1"
+		withKey := func(recipient string) *model.SendLog {
+			log := newLog("order:shipped", model.SendStatusSent, time.Now())
+			log.Recipient = recipient
+			log.IdempotencyKey = &key
+			return log
+		}
+		repo.Create(ctx, newLog("order:shipped", model.SendStatusSent, time.Now())) // 无幂等键不受约束
+		repo.Create(ctx, newLog("order:shipped", model.SendStatusSent, time.Now()))
+		if err := repo.Create(ctx, withKey("a@example.com")); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if err := repo.Create(ctx, withKey("b@example.com")); err != nil {
+			t.Fatalf("create second recipient: %v", err)
+		}
+		if err := repo.Create(ctx, withKey("a@example.com")); !errors.Is(err, ErrIdempotencyKeyUsed) {
+			t.Errorf("expected ErrIdempotencyKeyUsed, got %v", err)
+		}
+
+		logs, err := repo.ListByIdempotencyKey(ctx, key)
+		if err != nil {
+			t.Fatalf("list by key: %v", err)
+		}
+		if len(logs) != 2 || logs[0].Recipient != "a@example.com" || logs[1].Recipient != "b@example.com" {
+			t.Fatalf("unexpected logs: %+v", logs)
+		}
+
+		logs[0].IdempotencyKey = nil
+		repo.Update(ctx, &logs[0])
+		if logs, _ = repo.ListByIdempotencyKey(ctx, key); len(logs) != 1 {
+			t.Errorf("expected 1 log after clearing key, got %d", len(logs))
+		}
+	})
+}
+
+func testSendJobRepository(t *testing.T, newRepo func(t *testing.T) SendJobRepository) {
+	ctx := context.Background()
+	now := time.Now()
+
+	newJob := func(logID uint, availableAt time.Time) *model.SendJob {
+		return &model.SendJob{
+			SendLogID:   logID,
+			TriggerCode: "user:registered",
+			Payload:     "{}",
+			Status:      model.JobStatusQueued,
+			AvailableAt: availableAt,
+		}
+	}
+
+	t.Run("claim", func(t *testing.T) {
+		repo := newRepo(t)
+		repo.Create(ctx, newJob(1, now.Add(-2*time.Minute)))
+		repo.Create(ctx, newJob(2, now.Add(-time.Minute)))
+		repo.Create(ctx, newJob(3, now.Add(time.Hour))) // 未到期
+
+		jobs, err := repo.Claim(ctx, "w1", 10, now, time.Minute)
+		if err != nil {
+			t.Fatalf("claim: %v", err)
+		}
+		if len(jobs) != 2 {
+			t.Fatalf("expected 2 claimed jobs, got %d", len(jobs))
+		}
+		if jobs[0].SendLogID != 1 || jobs[0].Status != model.JobStatusProcessing || jobs[0].Attempts != 1 || jobs[0].LockedBy != "w1" {
+			t.Errorf("unexpected claimed job: %+v", jobs[0])
+		}
+
+		jobs, _ = repo.Claim(ctx, "w2", 10, now, time.Minute)
+		if len(jobs) != 0 {
+			t.Errorf("expected leased jobs not to be claimed again, got %d", len(jobs))
+		}
+
+		// 租约过期后可被重新领取
+		jobs, _ = repo.Claim(ctx, "w2", 1, now.Add(2*time.Minute), time.Minute)
+		if len(jobs) != 1 || jobs[0].Attempts != 2 || jobs[0].LockedBy != "w2" {
+			t.Errorf("expected expired lease to be reclaimed, got %+v", jobs)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		repo := newRepo(t)
+		job := newJob(1, now.Add(-time.Minute))
+		repo.Create(ctx, job)
+
+		jobs, _ := repo.Claim(ctx, "w1", 1, now, time.Minute)
+		jobs[0].MarkDone()
+		if err := repo.Update(ctx, jobs[0]); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+
+		jobs, _ = repo.Claim(ctx, "w1", 1, now.Add(time.Hour), time.Minute)
+		if len(jobs) != 0 {
+			t.Errorf("expected done job not to be claimed, got %d", len(jobs))
+		}
+	})
+
+	t.Run("release", func(t *testing.T) {
+		repo := newRepo(t)
+		repo.Create(ctx, newJob(1, now.Add(-time.Minute)))
+
+		stale, _ := repo.Claim(ctx, "w1", 1, now, time.Minute)
+		staleUntil := *stale[0].LockedUntil
+		current, _ := repo.Claim(ctx, "w2", 1, now.Add(2*time.Minute), time.Minute)
+		if len(current) != 1 {
+			t.Fatalf("expected expired lease to be reclaimed, got %d", len(current))
+		}
+
+		// 租约已被接管：原 Worker 的写回不生效
+		stale[0].MarkFailed("stale")
+		if ok, err := repo.Release(ctx, stale[0], "w1", staleUntil); err != nil || ok {
+			t.Fatalf("expected stale release to be rejected, got %v (%v)", ok, err)
+		}
+		if jobs, _ := repo.Claim(ctx, "w3", 1, now.Add(150*time.Second), time.Minute); len(jobs) != 0 {
+			t.Fatalf("expected job to stay leased by w2, got %+v", jobs)
+		}
+
+		currentUntil := *current[0].LockedUntil
+		current[0].MarkDone()
+		if ok, err := repo.Release(ctx, current[0], "w2", currentUntil); err != nil || !ok {
+			t.Fatalf("expected release by lease holder, got %v (%v)", ok, err)
+		}
+		if jobs, _ := repo.Claim(ctx, "w3", 1, now.Add(time.Hour), time.Minute); len(jobs) != 0 {
+			t.Errorf("expected done job not to be claimed, got %d", len(jobs))
+		}
+	})
+}
+
+func TestTemplateVersionRepository_Conformance(t *testing.T) {
+	t.Run("gorm", func(t *testing.T) {
+		testTemplateVersionRepository(t, func(t *testing.T) TemplateVersionRepository {
+			return NewGormTemplateVersionRepository(newTestDB(t))
+		})
+	})
+	t.Run("memory", func(t *testing.T) {
+		testTemplateVersionRepository(t, func(t *testing.T) TemplateVersionRepository { return NewMemoryTemplateVersionRepository() })
+	})
+}
+
+func testTemplateVersionRepository(t *testing.T, newRepo func(t *testing.T) TemplateVersionRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+
+	tpl := &model.Template{ID: 1, Subject: "v1", BodyHTML: "<p>v1</p>"}
+	for i := 1; i <= 3; i++ {
+		tpl.Subject = fmt.Sprintf("v%d", i)
+		if err := repo.Create(ctx, model.NewTemplateVersion(tpl, i, "admin", "")); err != nil {
+			t.Fatalf("create version %d: %v", i, err)
+		}
+	}
+	repo.Create(ctx, model.NewTemplateVersion(&model.Template{ID: 2, Subject: "other"}, 1, "", ""))
+
+	if err := repo.Create(ctx, model.NewTemplateVersion(tpl, 3, "", "")); err == nil {
+		t.Error("expected duplicate version to fail")
+	}
+
+	v, err := repo.Get(ctx, 1, 2)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if v.Subject != "v2" || v.Author != "admin" {
+		t.Errorf("unexpected version: %+v", v)
+	}
+	if _, err := repo.Get(ctx, 1, 9); !errors.Is(err, ErrTemplateVersionNotFound) {
+		t.Errorf("expected ErrTemplateVersionNotFound, got %v", err)
+	}
+
+	list, err := repo.ListByTemplate(ctx, 1)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(list) != 3 || list[0].Version != 3 || list[2].Version != 1 {
+		t.Errorf("expected 3 versions in DESC order, got %+v", list)
+	}
+}
+
+func TestTemplateAuditRepository_Conformance(t *testing.T) {
+	t.Run("gorm", func(t *testing.T) {
+		testTemplateAuditRepository(t, func(t *testing.T) TemplateAuditRepository {
+			return NewGormTemplateAuditRepository(newTestDB(t))
+		})
+	})
+	t.Run("memory", func(t *testing.T) {
+		testTemplateAuditRepository(t, func(t *testing.T) TemplateAuditRepository { return NewMemoryTemplateAuditRepository() })
+	})
+}
+
+func testTemplateAuditRepository(t *testing.T, newRepo func(t *testing.T) TemplateAuditRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	actions := []model.TemplateAuditAction{model.AuditActionCreate, model.AuditActionUpdate, model.AuditActionDelete}
+	for i, action := range actions {
+		audit := &model.TemplateAudit{TemplateID: 1, Action: action, Actor: "alice", CreatedAt: base.Add(time.Duration(i) * time.Minute)}
+		audit.SetChanges([]model.FieldChange{{Field: "subject", Before: "a", After: "b"}})
+		if err := repo.Create(ctx, audit); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	repo.Create(ctx, &model.TemplateAudit{TemplateID: 2, Action: model.AuditActionCreate, CreatedAt: base})
+
+	list, err := repo.ListByTemplate(ctx, 1)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(list) != 3 || list[0].Action != model.AuditActionDelete || list[2].Action != model.AuditActionCreate {
+		t.Fatalf("expected 3 audits in DESC order, got %+v", list)
+	}
+	if changes := list[0].FieldChanges(); len(changes) != 1 || changes[0].Field != "subject" || changes[0].After != "b" {
+		t.Errorf("unexpected changes: %+v", changes)
+	}
+}
+
+func TestPartialRepository_Conformance(t *testing.T) {
+	t.Run("gorm", func(t *testing.T) {
+		testPartialRepository(t, func(t *testing.T) PartialRepository { return NewGormPartialRepository(newTestDB(t)) })
+	})
+	t.Run("memory", func(t *testing.T) {
+		testPartialRepository(t, func(t *testing.T) PartialRepository { return NewMemoryPartialRepository() })
+	})
+}
+
+func testPartialRepository(t *testing.T, newRepo func(t *testing.T) PartialRepository) {
+	ctx := context.Background()
+	repo := newRepo(t)
+
+	footer := &model.Partial{Name: "footer", Language: "zh-CN", Kind: model.PartialKindPartial, BodyHTML: "<footer>页脚</footer>"}
+	if err := repo.Create(ctx, footer); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	repo.Create(ctx, &model.Partial{Name: "footer", Language: "en-US", Kind: model.PartialKindPartial, BodyHTML: "<footer>Footer</footer>"})
+	repo.Create(ctx, &model.Partial{Name: "base", Language: "zh-CN", Kind: model.PartialKindLayout, BodyHTML: "{{.Content}}"})
+
+	if err := repo.Create(ctx, &model.Partial{Name: "footer", Language: "zh-CN", BodyHTML: "dup"}); !errors.Is(err, ErrPartialExists) {
+		t.Errorf("expected ErrPartialExists, got %v", err)
+	}
+
+	got, err := repo.GetByName(ctx, "footer", "en-US")
+	if err != nil || got.BodyHTML != "<footer>Footer</footer>" {
+		t.Fatalf("get by name: %+v, %v", got, err)
+	}
+	if _, err := repo.GetByName(ctx, "footer", "ja-JP"); !errors.Is(err, ErrPartialNotFound) {
+		t.Errorf("expected ErrPartialNotFound, got %v", err)
+	}
+
+	footer.BodyHTML = "<footer>新页脚</footer>"
+	if err := repo.Update(ctx, footer); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if got, _ := repo.GetByID(ctx, footer.ID); got.BodyHTML != "<footer>新页脚</footer>" {
+		t.Errorf("expected updated body, got %s", got.BodyHTML)
+	}
+
+	list, _ := repo.List(ctx, PartialFilter{Language: "zh-CN"})
+	if len(list) != 2 || list[0].Name != "base" || list[1].Name != "footer" {
+		t.Errorf("unexpected zh-CN list: %+v", list)
+	}
+	list, _ = repo.List(ctx, PartialFilter{Kind: model.PartialKindLayout})
+	if len(list) != 1 || list[0].Name != "base" {
+		t.Errorf("unexpected layout list: %+v", list)
+	}
+
+	if err := repo.Delete(ctx, footer.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := repo.GetByID(ctx, footer.ID); !errors.Is(err, ErrPartialNotFound) {
+		t.Errorf("expected ErrPartialNotFound after delete, got %v", err)
+	}
+}
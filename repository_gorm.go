@@ -2,9 +2,12 @@ package email_notification
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/KOMKZ/go-yogan-domain-email-notification/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // ============ Template Repository GORM 实现 ============
@@ -19,11 +22,39 @@ func NewGormTemplateRepository(db *gorm.DB) TemplateRepository {
 }
 
 func (r *gormTemplateRepository) Create(ctx context.Context, template *model.Template) error {
-	return r.db.WithContext(ctx).Create(template).Error
+	err := r.db.WithContext(ctx).Create(template).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrTemplateExists
+	}
+	return err
 }
 
 func (r *gormTemplateRepository) Update(ctx context.Context, template *model.Template) error {
-	return r.db.WithContext(ctx).Save(template).Error
+	expected := template.Revision
+	template.Revision++
+
+	// 仅当数据库中的修订号与读取时一致才更新
+	result := r.db.WithContext(ctx).Model(template).
+		Where("revision = ?", expected).
+		Select("*").Omit("id", "created_at").
+		Updates(template)
+	if result.Error != nil {
+		template.Revision = expected
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	template.Revision = expected
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&model.Template{}).Where("id = ?", template.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrTemplateNotFound
+	}
+	return ErrTemplateConflict
 }
 
 func (r *gormTemplateRepository) Delete(ctx context.Context, id uint) error {
@@ -130,7 +161,25 @@ func NewGormSendLogRepository(db *gorm.DB) SendLogRepository {
 }
 
 func (r *gormSendLogRepository) Create(ctx context.Context, log *model.SendLog) error {
-	return r.db.WithContext(ctx).Create(log).Error
+	if log.IdempotencyKey != nil {
+		var count int64
+		err := r.db.WithContext(ctx).Model(&model.SendLog{}).
+			Where("idempotency_key = ? AND recipient = ?", *log.IdempotencyKey, log.Recipient).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrIdempotencyKeyUsed
+		}
+	}
+
+	// 并发创建由唯一索引兜底（需开启 gorm.Config.TranslateError 才能识别）
+	err := r.db.WithContext(ctx).Create(log).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrIdempotencyKeyUsed
+	}
+	return err
 }
 
 func (r *gormSendLogRepository) Update(ctx context.Context, log *model.SendLog) error {
@@ -150,20 +199,7 @@ func (r *gormSendLogRepository) GetByID(ctx context.Context, id uint) (*model.Se
 }
 
 func (r *gormSendLogRepository) List(ctx context.Context, filter LogFilter) (*PageResult[model.SendLog], error) {
-	query := r.db.WithContext(ctx).Model(&model.SendLog{})
-
-	if filter.TriggerCode != "" {
-		query = query.Where("trigger_code = ?", filter.TriggerCode)
-	}
-	if filter.Status != "" {
-		query = query.Where("status = ?", filter.Status)
-	}
-	if filter.StartTime != "" {
-		query = query.Where("created_at >= ?", filter.StartTime)
-	}
-	if filter.EndTime != "" {
-		query = query.Where("created_at <= ?", filter.EndTime)
-	}
+	query := r.filtered(ctx, filter)
 
 	var total int64
 	if err := query.Count(&total).Error; err != nil {
@@ -198,3 +234,293 @@ func (r *gormSendLogRepository) List(ctx context.Context, filter LogFilter) (*Pa
 		TotalPages: totalPages,
 	}, nil
 }
+
+func (r *gormSendLogRepository) ListAfterID(ctx context.Context, filter LogFilter, afterID uint, limit int) ([]model.SendLog, error) {
+	if limit < 1 {
+		limit = 20
+	}
+
+	var items []model.SendLog
+	err := r.filtered(ctx, filter).Where("id > ?", afterID).Order("id ASC").Limit(limit).Find(&items).Error
+	if err != nil {
+		return nil, ErrDatabaseError.Wrap(err)
+	}
+	return items, nil
+}
+
+func (r *gormSendLogRepository) ExistsNewer(ctx context.Context, triggerCode, recipient string, afterID uint) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.SendLog{}).
+		Where("trigger_code = ? AND recipient = ? AND id > ? AND status = ?", triggerCode, recipient, afterID, model.SendStatusSent).
+		Limit(1).Count(&count).Error
+	if err != nil {
+		return false, ErrDatabaseError.Wrap(err)
+	}
+	return count > 0, nil
+}
+
+func (r *gormSendLogRepository) ExistsResend(ctx context.Context, parentID uint) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.SendLog{}).
+		Where("parent_log_id = ?", parentID).
+		Limit(1).Count(&count).Error
+	if err != nil {
+		return false, ErrDatabaseError.Wrap(err)
+	}
+	return count > 0, nil
+}
+
+func (r *gormSendLogRepository) ListByIdempotencyKey(ctx context.Context, key string) ([]model.SendLog, error) {
+	var items []model.SendLog
+	if err := r.db.WithContext(ctx).Where("idempotency_key = ?", key).Order("id ASC").Find(&items).Error; err != nil {
+		return nil, ErrDatabaseError.Wrap(err)
+	}
+	return items, nil
+}
+
+// filtered 按 LogFilter 的查询条件构造查询（不含分页）
+func (r *gormSendLogRepository) filtered(ctx context.Context, filter LogFilter) *gorm.DB {
+	query := r.db.WithContext(ctx).Model(&model.SendLog{})
+
+	if filter.TriggerCode != "" {
+		query = query.Where("trigger_code = ?", filter.TriggerCode)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.StartTime != "" {
+		query = query.Where("created_at >= ?", filter.StartTime)
+	}
+	if filter.EndTime != "" {
+		query = query.Where("created_at <= ?", filter.EndTime)
+	}
+	return query
+}
+
+// ============ SendJob Repository GORM 实现 ============
+
+type gormSendJobRepository struct {
+	db *gorm.DB
+}
+
+// NewGormSendJobRepository 创建 GORM 异步发送队列仓储
+func NewGormSendJobRepository(db *gorm.DB) SendJobRepository {
+	return &gormSendJobRepository{db: db}
+}
+
+func (r *gormSendJobRepository) Create(ctx context.Context, job *model.SendJob) error {
+	return r.db.WithContext(ctx).Create(job).Error
+}
+
+func (r *gormSendJobRepository) Update(ctx context.Context, job *model.SendJob) error {
+	return r.db.WithContext(ctx).Save(job).Error
+}
+
+func (r *gormSendJobRepository) Release(ctx context.Context, job *model.SendJob, lockedBy string, lockedUntil time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&model.SendJob{}).
+		Where("id = ? AND locked_by = ? AND locked_until = ?", job.ID, lockedBy, lockedUntil).
+		Updates(map[string]any{
+			"status":       job.Status,
+			"available_at": job.AvailableAt,
+			"locked_by":    job.LockedBy,
+			"locked_until": job.LockedUntil,
+			"last_error":   job.LastError,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *gormSendJobRepository) Claim(ctx context.Context, workerID string, limit int, now time.Time, lease time.Duration) ([]*model.SendJob, error) {
+	if limit < 1 {
+		limit = 1
+	}
+
+	var jobs []*model.SendJob
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 行锁 + SKIP LOCKED：多个 Worker 并发领取时互不阻塞、不重复
+		err := tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate, Options: clause.LockingOptionsSkipLocked}).
+			Where("(status = ? AND available_at <= ?) OR (status = ? AND locked_until < ?)",
+				model.JobStatusQueued, now, model.JobStatusProcessing, now).
+			Order("available_at ASC, id ASC").
+			Limit(limit).
+			Find(&jobs).Error
+		if err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, 0, len(jobs))
+		lockedUntil := leaseUntil(now, lease)
+		for _, job := range jobs {
+			ids = append(ids, job.ID)
+			job.Status = model.JobStatusProcessing
+			job.LockedBy = workerID
+			job.LockedUntil = &lockedUntil
+			job.Attempts++
+		}
+
+		return tx.Model(&model.SendJob{}).Where("id IN ?", ids).Updates(map[string]any{
+			"status":       model.JobStatusProcessing,
+			"locked_by":    workerID,
+			"locked_until": lockedUntil,
+			"attempts":     gorm.Expr("attempts + 1"),
+		}).Error
+	})
+	if err != nil {
+		return nil, ErrDatabaseError.Wrap(err)
+	}
+	return jobs, nil
+}
+
+// ============ TemplateVersion Repository GORM 实现 ============
+
+type gormTemplateVersionRepository struct {
+	db *gorm.DB
+}
+
+// NewGormTemplateVersionRepository 创建 GORM 模板版本仓储
+func NewGormTemplateVersionRepository(db *gorm.DB) TemplateVersionRepository {
+	return &gormTemplateVersionRepository{db: db}
+}
+
+func (r *gormTemplateVersionRepository) Create(ctx context.Context, version *model.TemplateVersion) error {
+	return r.db.WithContext(ctx).Create(version).Error
+}
+
+func (r *gormTemplateVersionRepository) Get(ctx context.Context, templateID uint, version int) (*model.TemplateVersion, error) {
+	var v model.TemplateVersion
+	err := r.db.WithContext(ctx).
+		Where("template_id = ? AND version = ?", templateID, version).
+		First(&v).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrTemplateVersionNotFound
+		}
+		return nil, ErrDatabaseError.Wrap(err)
+	}
+	return &v, nil
+}
+
+func (r *gormTemplateVersionRepository) ListByTemplate(ctx context.Context, templateID uint) ([]model.TemplateVersion, error) {
+	var items []model.TemplateVersion
+	err := r.db.WithContext(ctx).
+		Where("template_id = ?", templateID).
+		Order("version DESC").
+		Find(&items).Error
+	if err != nil {
+		return nil, ErrDatabaseError.Wrap(err)
+	}
+	return items, nil
+}
+
+// ============ TemplateAudit Repository GORM 实现 ============
+
+type gormTemplateAuditRepository struct {
+	db *gorm.DB
+}
+
+// NewGormTemplateAuditRepository 创建 GORM 模板审计仓储
+func NewGormTemplateAuditRepository(db *gorm.DB) TemplateAuditRepository {
+	return &gormTemplateAuditRepository{db: db}
+}
+
+func (r *gormTemplateAuditRepository) Create(ctx context.Context, audit *model.TemplateAudit) error {
+	return r.db.WithContext(ctx).Create(audit).Error
+}
+
+func (r *gormTemplateAuditRepository) ListByTemplate(ctx context.Context, templateID uint) ([]model.TemplateAudit, error) {
+	var items []model.TemplateAudit
+	err := r.db.WithContext(ctx).
+		Where("template_id = ?", templateID).
+		Order("created_at DESC, id DESC").
+		Find(&items).Error
+	if err != nil {
+		return nil, ErrDatabaseError.Wrap(err)
+	}
+	return items, nil
+}
+
+// ============ Partial Repository GORM 实现 ============
+
+type gormPartialRepository struct {
+	db *gorm.DB
+}
+
+// NewGormPartialRepository 创建 GORM 布局与片段仓储
+func NewGormPartialRepository(db *gorm.DB) PartialRepository {
+	return &gormPartialRepository{db: db}
+}
+
+func (r *gormPartialRepository) Create(ctx context.Context, partial *model.Partial) error {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.Partial{}).
+		Where("name = ? AND language = ?", partial.Name, partial.Language).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrPartialExists
+	}
+
+	// 并发创建由唯一索引兜底（需开启 gorm.Config.TranslateError 才能识别）
+	err = r.db.WithContext(ctx).Create(partial).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrPartialExists
+	}
+	return err
+}
+
+func (r *gormPartialRepository) Update(ctx context.Context, partial *model.Partial) error {
+	return r.db.WithContext(ctx).Save(partial).Error
+}
+
+func (r *gormPartialRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&model.Partial{}, id).Error
+}
+
+func (r *gormPartialRepository) GetByID(ctx context.Context, id uint) (*model.Partial, error) {
+	var partial model.Partial
+	err := r.db.WithContext(ctx).First(&partial, id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrPartialNotFound
+		}
+		return nil, ErrDatabaseError.Wrap(err)
+	}
+	return &partial, nil
+}
+
+func (r *gormPartialRepository) GetByName(ctx context.Context, name, language string) (*model.Partial, error) {
+	var partial model.Partial
+	err := r.db.WithContext(ctx).
+		Where("name = ? AND language = ?", name, language).
+		First(&partial).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrPartialNotFound
+		}
+		return nil, ErrDatabaseError.Wrap(err)
+	}
+	return &partial, nil
+}
+
+func (r *gormPartialRepository) List(ctx context.Context, filter PartialFilter) ([]model.Partial, error) {
+	query := r.db.WithContext(ctx).Model(&model.Partial{})
+	if filter.Language != "" {
+		query = query.Where("language = ?", filter.Language)
+	}
+	if filter.Kind != "" {
+		query = query.Where("kind = ?", filter.Kind)
+	}
+
+	var items []model.Partial
+	if err := query.Order("name ASC, language ASC").Find(&items).Error; err != nil {
+		return nil, ErrDatabaseError.Wrap(err)
+	}
+	return items, nil
+}
@@ -0,0 +1,674 @@
+package email_notification
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KOMKZ/go-yogan-domain-email-notification/model"
+)
+
+// ============ Template Repository 内存实现 ============
+
+type memoryTemplateRepository struct {
+	mu        sync.RWMutex
+	nextID    uint
+	templates map[uint]model.Template
+}
+
+// NewMemoryTemplateRepository 创建内存模板仓储（并发安全，适用于测试与嵌入式部署）
+func NewMemoryTemplateRepository() TemplateRepository {
+	return &memoryTemplateRepository{templates: make(map[uint]model.Template)}
+}
+
+func (r *memoryTemplateRepository) Create(ctx context.Context, template *model.Template) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if template.ID == 0 {
+		r.nextID++
+		template.ID = r.nextID
+	} else if template.ID > r.nextID {
+		r.nextID = template.ID
+	}
+	if template.CreatedAt.IsZero() {
+		template.CreatedAt = now
+	}
+	if template.UpdatedAt.IsZero() {
+		template.UpdatedAt = now
+	}
+	if template.Language == "" {
+		template.Language = "zh-CN"
+	}
+	if template.Status == "" {
+		template.Status = model.TemplateStatusDraft
+	}
+	r.templates[template.ID] = *template
+	return nil
+}
+
+func (r *memoryTemplateRepository) Update(ctx context.Context, template *model.Template) error {
+	if template.ID == 0 {
+		return r.Create(ctx, template)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.templates[template.ID]
+	if !ok {
+		return ErrTemplateNotFound
+	}
+	if current.Revision != template.Revision {
+		return ErrTemplateConflict
+	}
+
+	template.Revision++
+	template.UpdatedAt = time.Now()
+	template.CreatedAt = current.CreatedAt
+	r.templates[template.ID] = *template
+	return nil
+}
+
+func (r *memoryTemplateRepository) Delete(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.templates, id)
+	return nil
+}
+
+func (r *memoryTemplateRepository) GetByID(ctx context.Context, id uint) (*model.Template, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	template, ok := r.templates[id]
+	if !ok {
+		return nil, ErrTemplateNotFound
+	}
+	return &template, nil
+}
+
+func (r *memoryTemplateRepository) GetActiveTemplate(ctx context.Context, triggerCode, language string) (*model.Template, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var found *model.Template
+	for _, template := range r.templates {
+		if template.TriggerCode == triggerCode && template.Language == language && template.Status == model.TemplateStatusEnabled {
+			if found == nil || template.ID < found.ID {
+				t := template
+				found = &t
+			}
+		}
+	}
+	if found == nil {
+		return nil, ErrTemplateNotFound
+	}
+	return found, nil
+}
+
+func (r *memoryTemplateRepository) List(ctx context.Context, filter TemplateFilter) (*PageResult[model.Template], error) {
+	r.mu.RLock()
+	items := make([]model.Template, 0, len(r.templates))
+	for _, template := range r.templates {
+		if filter.TriggerCode != "" && template.TriggerCode != filter.TriggerCode {
+			continue
+		}
+		if filter.Language != "" && template.Language != filter.Language {
+			continue
+		}
+		if filter.Status != "" && template.Status != filter.Status {
+			continue
+		}
+		items = append(items, template)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].CreatedAt.Equal(items[j].CreatedAt) {
+			return items[i].CreatedAt.After(items[j].CreatedAt)
+		}
+		return items[i].ID > items[j].ID
+	})
+
+	return paginate(items, filter.Page, filter.PageSize), nil
+}
+
+func (r *memoryTemplateRepository) ExistsByTriggerAndLanguage(ctx context.Context, triggerCode, language string, excludeID uint) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, template := range r.templates {
+		if template.TriggerCode == triggerCode && template.Language == language && template.ID != excludeID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ============ SendLog Repository 内存实现 ============
+
+type memorySendLogRepository struct {
+	mu     sync.RWMutex
+	nextID uint
+	logs   map[uint]model.SendLog
+}
+
+// NewMemorySendLogRepository 创建内存发送日志仓储
+func NewMemorySendLogRepository() SendLogRepository {
+	return &memorySendLogRepository{logs: make(map[uint]model.SendLog)}
+}
+
+func (r *memorySendLogRepository) Create(ctx context.Context, log *model.SendLog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if log.IdempotencyKey != nil {
+		for _, l := range r.logs {
+			if l.IdempotencyKey != nil && *l.IdempotencyKey == *log.IdempotencyKey && l.Recipient == log.Recipient {
+				return ErrIdempotencyKeyUsed
+			}
+		}
+	}
+
+	if log.ID == 0 {
+		r.nextID++
+		log.ID = r.nextID
+	} else if log.ID > r.nextID {
+		r.nextID = log.ID
+	}
+	if log.CreatedAt.IsZero() {
+		log.CreatedAt = time.Now()
+	}
+	if log.Status == "" {
+		log.Status = model.SendStatusPending
+	}
+	r.logs[log.ID] = *log
+	return nil
+}
+
+func (r *memorySendLogRepository) Update(ctx context.Context, log *model.SendLog) error {
+	if log.ID == 0 {
+		return r.Create(ctx, log)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.logs[log.ID] = *log
+	return nil
+}
+
+func (r *memorySendLogRepository) GetByID(ctx context.Context, id uint) (*model.SendLog, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	log, ok := r.logs[id]
+	if !ok {
+		return nil, ErrSendLogNotFound
+	}
+	return &log, nil
+}
+
+func (r *memorySendLogRepository) List(ctx context.Context, filter LogFilter) (*PageResult[model.SendLog], error) {
+	items := r.filtered(filter, 0)
+
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].CreatedAt.Equal(items[j].CreatedAt) {
+			return items[i].CreatedAt.After(items[j].CreatedAt)
+		}
+		return items[i].ID > items[j].ID
+	})
+
+	return paginate(items, filter.Page, filter.PageSize), nil
+}
+
+func (r *memorySendLogRepository) ListAfterID(ctx context.Context, filter LogFilter, afterID uint, limit int) ([]model.SendLog, error) {
+	if limit < 1 {
+		limit = 20
+	}
+
+	items := r.filtered(filter, afterID)
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+func (r *memorySendLogRepository) ExistsNewer(ctx context.Context, triggerCode, recipient string, afterID uint) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, log := range r.logs {
+		if log.ID > afterID && log.TriggerCode == triggerCode && log.Recipient == recipient && log.Status == model.SendStatusSent {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memorySendLogRepository) ExistsResend(ctx context.Context, parentID uint) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, log := range r.logs {
+		if log.ParentLogID != nil && *log.ParentLogID == parentID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memorySendLogRepository) ListByIdempotencyKey(ctx context.Context, key string) ([]model.SendLog, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var items []model.SendLog
+	for _, log := range r.logs {
+		if log.IdempotencyKey != nil && *log.IdempotencyKey == key {
+			items = append(items, log)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items, nil
+}
+
+// filtered 返回满足查询条件且 ID 大于 afterID 的日志（无序）
+func (r *memorySendLogRepository) filtered(filter LogFilter, afterID uint) []model.SendLog {
+	startTime, hasStart := parseFilterTime(filter.StartTime)
+	endTime, hasEnd := parseFilterTime(filter.EndTime)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	items := make([]model.SendLog, 0, len(r.logs))
+	for _, log := range r.logs {
+		if log.ID <= afterID {
+			continue
+		}
+		if filter.TriggerCode != "" && log.TriggerCode != filter.TriggerCode {
+			continue
+		}
+		if filter.Status != "" && log.Status != filter.Status {
+			continue
+		}
+		if hasStart && log.CreatedAt.Before(startTime) {
+			continue
+		}
+		if hasEnd && log.CreatedAt.After(endTime) {
+			continue
+		}
+		items = append(items, log)
+	}
+	return items
+}
+
+// ============ SendJob Repository 内存实现 ============
+
+type memorySendJobRepository struct {
+	mu     sync.Mutex
+	nextID uint
+	jobs   map[uint]model.SendJob
+}
+
+// NewMemorySendJobRepository 创建内存发送队列仓储（仅单进程内有效，重启后队列丢失）
+func NewMemorySendJobRepository() SendJobRepository {
+	return &memorySendJobRepository{jobs: make(map[uint]model.SendJob)}
+}
+
+func (r *memorySendJobRepository) Create(ctx context.Context, job *model.SendJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if job.ID == 0 {
+		r.nextID++
+		job.ID = r.nextID
+	} else if job.ID > r.nextID {
+		r.nextID = job.ID
+	}
+	now := time.Now()
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = now
+	}
+	job.UpdatedAt = now
+	if job.Status == "" {
+		job.Status = model.JobStatusQueued
+	}
+	r.jobs[job.ID] = *job
+	return nil
+}
+
+func (r *memorySendJobRepository) Update(ctx context.Context, job *model.SendJob) error {
+	if job.ID == 0 {
+		return r.Create(ctx, job)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job.UpdatedAt = time.Now()
+	r.jobs[job.ID] = *job
+	return nil
+}
+
+func (r *memorySendJobRepository) Release(ctx context.Context, job *model.SendJob, lockedBy string, lockedUntil time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.jobs[job.ID]
+	if !ok || current.LockedBy != lockedBy || current.LockedUntil == nil || !current.LockedUntil.Equal(lockedUntil) {
+		return false, nil
+	}
+	job.UpdatedAt = time.Now()
+	r.jobs[job.ID] = *job
+	return true, nil
+}
+
+func (r *memorySendJobRepository) Claim(ctx context.Context, workerID string, limit int, now time.Time, lease time.Duration) ([]*model.SendJob, error) {
+	if limit < 1 {
+		limit = 1
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var candidates []model.SendJob
+	for _, job := range r.jobs {
+		queued := job.Status == model.JobStatusQueued && !job.AvailableAt.After(now)
+		expired := job.Status == model.JobStatusProcessing && job.LockedUntil != nil && job.LockedUntil.Before(now)
+		if queued || expired {
+			candidates = append(candidates, job)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if !candidates[i].AvailableAt.Equal(candidates[j].AvailableAt) {
+			return candidates[i].AvailableAt.Before(candidates[j].AvailableAt)
+		}
+		return candidates[i].ID < candidates[j].ID
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	lockedUntil := leaseUntil(now, lease)
+	jobs := make([]*model.SendJob, 0, len(candidates))
+	for _, job := range candidates {
+		job.Status = model.JobStatusProcessing
+		job.LockedBy = workerID
+		job.LockedUntil = &lockedUntil
+		job.Attempts++
+		r.jobs[job.ID] = job
+
+		claimed := job
+		jobs = append(jobs, &claimed)
+	}
+	return jobs, nil
+}
+
+// ============ TemplateVersion Repository 内存实现 ============
+
+type memoryTemplateVersionRepository struct {
+	mu       sync.RWMutex
+	nextID   uint
+	versions map[uint][]model.TemplateVersion // template_id -> 版本列表（按版本号升序）
+}
+
+// NewMemoryTemplateVersionRepository 创建内存模板版本仓储
+func NewMemoryTemplateVersionRepository() TemplateVersionRepository {
+	return &memoryTemplateVersionRepository{versions: make(map[uint][]model.TemplateVersion)}
+}
+
+func (r *memoryTemplateVersionRepository) Create(ctx context.Context, version *model.TemplateVersion) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, v := range r.versions[version.TemplateID] {
+		if v.Version == version.Version {
+			return ErrDatabaseError.WithMsg("模板版本已存在")
+		}
+	}
+
+	r.nextID++
+	version.ID = r.nextID
+	if version.CreatedAt.IsZero() {
+		version.CreatedAt = time.Now()
+	}
+	list := append(r.versions[version.TemplateID], *version)
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	r.versions[version.TemplateID] = list
+	return nil
+}
+
+func (r *memoryTemplateVersionRepository) Get(ctx context.Context, templateID uint, version int) (*model.TemplateVersion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, v := range r.versions[templateID] {
+		if v.Version == version {
+			return &v, nil
+		}
+	}
+	return nil, ErrTemplateVersionNotFound
+}
+
+func (r *memoryTemplateVersionRepository) ListByTemplate(ctx context.Context, templateID uint) ([]model.TemplateVersion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := r.versions[templateID]
+	items := make([]model.TemplateVersion, 0, len(list))
+	for i := len(list) - 1; i >= 0; i-- {
+		items = append(items, list[i])
+	}
+	return items, nil
+}
+
+// ============ TemplateAudit Repository 内存实现 ============
+
+type memoryTemplateAuditRepository struct {
+	mu     sync.RWMutex
+	nextID uint
+	audits []model.TemplateAudit
+}
+
+// NewMemoryTemplateAuditRepository 创建内存模板审计仓储
+func NewMemoryTemplateAuditRepository() TemplateAuditRepository {
+	return &memoryTemplateAuditRepository{}
+}
+
+func (r *memoryTemplateAuditRepository) Create(ctx context.Context, audit *model.TemplateAudit) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	audit.ID = r.nextID
+	if audit.CreatedAt.IsZero() {
+		audit.CreatedAt = time.Now()
+	}
+	r.audits = append(r.audits, *audit)
+	return nil
+}
+
+func (r *memoryTemplateAuditRepository) ListByTemplate(ctx context.Context, templateID uint) ([]model.TemplateAudit, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var items []model.TemplateAudit
+	for _, a := range r.audits {
+		if a.TemplateID == templateID {
+			items = append(items, a)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if !items[i].CreatedAt.Equal(items[j].CreatedAt) {
+			return items[i].CreatedAt.After(items[j].CreatedAt)
+		}
+		return items[i].ID > items[j].ID
+	})
+	return items, nil
+}
+
+// ============ Partial Repository 内存实现 ============
+
+type memoryPartialRepository struct {
+	mu       sync.RWMutex
+	nextID   uint
+	partials map[uint]model.Partial
+}
+
+// NewMemoryPartialRepository 创建内存布局与片段仓储
+func NewMemoryPartialRepository() PartialRepository {
+	return &memoryPartialRepository{partials: make(map[uint]model.Partial)}
+}
+
+func (r *memoryPartialRepository) Create(ctx context.Context, partial *model.Partial) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if partial.Language == "" {
+		partial.Language = "zh-CN"
+	}
+	if partial.Kind == "" {
+		partial.Kind = model.PartialKindPartial
+	}
+	for _, p := range r.partials {
+		if p.Name == partial.Name && p.Language == partial.Language {
+			return ErrPartialExists
+		}
+	}
+
+	now := time.Now()
+	r.nextID++
+	partial.ID = r.nextID
+	if partial.CreatedAt.IsZero() {
+		partial.CreatedAt = now
+	}
+	partial.UpdatedAt = now
+	r.partials[partial.ID] = *partial
+	return nil
+}
+
+func (r *memoryPartialRepository) Update(ctx context.Context, partial *model.Partial) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.partials[partial.ID]
+	if !ok {
+		return ErrPartialNotFound
+	}
+	partial.CreatedAt = current.CreatedAt
+	partial.UpdatedAt = time.Now()
+	r.partials[partial.ID] = *partial
+	return nil
+}
+
+func (r *memoryPartialRepository) Delete(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.partials, id)
+	return nil
+}
+
+func (r *memoryPartialRepository) GetByID(ctx context.Context, id uint) (*model.Partial, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	partial, ok := r.partials[id]
+	if !ok {
+		return nil, ErrPartialNotFound
+	}
+	return &partial, nil
+}
+
+func (r *memoryPartialRepository) GetByName(ctx context.Context, name, language string) (*model.Partial, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, p := range r.partials {
+		if p.Name == name && p.Language == language {
+			return &p, nil
+		}
+	}
+	return nil, ErrPartialNotFound
+}
+
+func (r *memoryPartialRepository) List(ctx context.Context, filter PartialFilter) ([]model.Partial, error) {
+	r.mu.RLock()
+	items := make([]model.Partial, 0, len(r.partials))
+	for _, p := range r.partials {
+		if filter.Language != "" && p.Language != filter.Language {
+			continue
+		}
+		if filter.Kind != "" && p.Kind != filter.Kind {
+			continue
+		}
+		items = append(items, p)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Name != items[j].Name {
+			return items[i].Name < items[j].Name
+		}
+		return items[i].Language < items[j].Language
+	})
+	return items, nil
+}
+
+// ============ 工具函数 ============
+
+// paginate 内存分页（与 GORM 实现的默认值一致）
+func paginate[T any](items []T, page, pageSize int) *PageResult[T] {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	total := len(items)
+	offset := (page - 1) * pageSize
+	end := offset + pageSize
+	if offset > total {
+		offset = total
+	}
+	if end > total {
+		end = total
+	}
+
+	totalPages := total / pageSize
+	if total%pageSize > 0 {
+		totalPages++
+	}
+
+	return &PageResult[T]{
+		Items:      append([]T{}, items[offset:end]...),
+		Total:      int64(total),
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: totalPages,
+	}
+}
+
+// filterTimeLayouts 日志筛选时间支持的格式
+var filterTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"}
+
+// parseFilterTime 解析筛选时间（本地时区）
+func parseFilterTime(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range filterTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
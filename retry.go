@@ -0,0 +1,93 @@
+package email_notification
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy 发送失败重试策略
+type RetryPolicy struct {
+	MaxAttempts int           `json:"max_attempts"` // 最大尝试次数（含首次，<=1 表示不重试）
+	BaseDelay   time.Duration `json:"base_delay"`   // 首次重试延迟，之后按 2 的幂次递增
+	MaxDelay    time.Duration `json:"max_delay"`    // 单次延迟上限（0 表示不限制）
+	Jitter      float64       `json:"jitter"`       // 抖动比例（0~1），避免大量任务同时重试
+}
+
+// DefaultRetryPolicy 默认重试策略
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   30 * time.Second,
+	MaxDelay:    30 * time.Minute,
+	Jitter:      0.2,
+}
+
+// NoRetry 不重试
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// Backoff 计算第 attempt 次尝试失败后的等待时长（attempt 从 1 开始）
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			delay = p.MaxDelay
+			break
+		}
+		if delay <= 0 { // 溢出
+			delay = p.MaxDelay
+			break
+		}
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if p.Jitter > 0 && delay > 0 {
+		delta := float64(delay) * p.Jitter * (2*rand.Float64() - 1)
+		delay += time.Duration(delta)
+	}
+	return delay
+}
+
+// ShouldRetry 是否应在第 attempt 次尝试失败后重试
+func (p RetryPolicy) ShouldRetry(attempt int, err error) bool {
+	return attempt < p.MaxAttempts && IsTransientSendError(err)
+}
+
+// smtpReplyPattern 匹配错误信息开头的 SMTP 应答码（如 "421 Service not available"）
+// 允许 "smtp: 421 ..." 这类包装前缀，不匹配正文中间的数字（如 "i/o timeout after 500 ms"）
+var smtpReplyPattern = regexp.MustCompile(`^(?:[^:]+: )*([2-5]\d{2})[ -]`)
+
+// IsTransientSendError 判断发送错误是否为临时错误
+// SMTP 4xx 与网络错误视为临时错误可重试，SMTP 5xx 为永久错误直接失败
+func IsTransientSendError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var tpErr *textproto.Error
+	if errors.As(err, &tpErr) {
+		return tpErr.Code >= 400 && tpErr.Code < 500
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	if m := smtpReplyPattern.FindStringSubmatch(err.Error()); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		return code >= 400 && code < 500
+	}
+
+	// 连接失败、超时等无状态码的错误按临时错误处理
+	return true
+}
@@ -0,0 +1,86 @@
+package email_notification
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	tests := []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{attempt: 1, expected: time.Second},
+		{attempt: 2, expected: 2 * time.Second},
+		{attempt: 3, expected: 4 * time.Second},
+		{attempt: 4, expected: 5 * time.Second}, // 达到上限
+		{attempt: 50, expected: 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := policy.Backoff(tt.attempt); got != tt.expected {
+			t.Errorf("attempt %d: expected %v, got %v", tt.attempt, tt.expected, got)
+		}
+	}
+}
+
+func TestRetryPolicy_BackoffJitter(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Second, Jitter: 0.5}
+
+	for i := 0; i < 100; i++ {
+		got := policy.Backoff(1)
+		if got < 5*time.Second || got > 15*time.Second {
+			t.Fatalf("expected delay within [5s, 15s], got %v", got)
+		}
+	}
+}
+
+func TestRetryPolicy_ShouldRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second}
+	transient := &textproto.Error{Code: 421, Msg: "Service not available"}
+	permanent := &textproto.Error{Code: 550, Msg: "Mailbox unavailable"}
+
+	if !policy.ShouldRetry(1, transient) {
+		t.Error("expected transient error to be retried")
+	}
+	if policy.ShouldRetry(3, transient) {
+		t.Error("expected no retry after max attempts")
+	}
+	if policy.ShouldRetry(1, permanent) {
+		t.Error("expected permanent error not to be retried")
+	}
+	if NoRetry.ShouldRetry(1, transient) {
+		t.Error("expected NoRetry never to retry")
+	}
+}
+
+func TestIsTransientSendError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "nil", err: nil, expected: false},
+		{name: "smtp 4xx", err: &textproto.Error{Code: 451, Msg: "try again later"}, expected: true},
+		{name: "smtp 5xx", err: &textproto.Error{Code: 554, Msg: "rejected"}, expected: false},
+		{name: "wrapped smtp 5xx", err: fmt.Errorf("send: %w", &textproto.Error{Code: 550, Msg: "no such user"}), expected: false},
+		{name: "4xx in message", err: errors.New("smtp: 421 4.7.0 Too many connections"), expected: true},
+		{name: "5xx in message", err: errors.New("550 5.1.1 User unknown"), expected: false},
+		{name: "network error", err: errors.New("dial tcp 10.0.0.1:465: connect: connection refused"), expected: true},
+		{name: "number in network error", err: errors.New("dial tcp: i/o timeout after 500 ms"), expected: true},
+		{name: "5xx after wrap prefixes", err: errors.New("send mail: smtp: 550 5.1.1 User unknown"), expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransientSendError(tt.err); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package email_notification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/KOMKZ/go-yogan-domain-email-notification/model"
+)
+
+// SeedTemplate 种子模板文件（JSON），正文可内联或引用同目录下的文件
+//
+//	{
+//	  "trigger_code": "user.registered",
+//	  "language": "zh-CN",
+//	  "name": "注册欢迎",
+//	  "subject": "欢迎加入 {{.AppName}}",
+//	  "body_html_file": "user_registered.zh-CN.html"
+//	}
+type SeedTemplate struct {
+	CreateTemplateInput
+	BodyHTMLFile string `json:"body_html_file"` // HTML 正文文件（相对当前 JSON 文件）
+	BodyTextFile string `json:"body_text_file"` // 纯文本正文文件（相对当前 JSON 文件）
+}
+
+// SeedResult 种子导入结果
+type SeedResult struct {
+	Created []string `json:"created"` // trigger_code/language
+	Skipped []string `json:"skipped"` // 已存在而跳过
+}
+
+// SeedTemplates 从文件系统（通常为 embed.FS）导入默认模板
+// 遍历全部 *.json 种子文件，已存在相同触发点和语言的模板跳过；未指定状态时默认启用
+// 种子模板随代码评审发布，视为已审核（审核人记为 SeedReviewer），不再走审核流程
+func (s *Service) SeedTemplates(ctx context.Context, fsys fs.FS) (*SeedResult, error) {
+	result := &SeedResult{}
+
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(p, ".json") {
+			return nil
+		}
+
+		seed, err := loadSeedTemplate(fsys, p)
+		if err != nil {
+			return err
+		}
+
+		input := seed.CreateTemplateInput
+		if input.Language, err = s.normalizeLanguage(input.Language); err != nil {
+			return err
+		}
+		if input.Status == "" {
+			input.Status = model.TemplateStatusEnabled
+		}
+		key := input.TriggerCode + "/" + input.Language
+
+		exists, err := s.templateRepo.ExistsByTriggerAndLanguage(ctx, input.TriggerCode, input.Language, 0)
+		if err != nil {
+			return err
+		}
+		if exists {
+			result.Skipped = append(result.Skipped, key)
+			return nil
+		}
+
+		if err := s.seedTemplate(ctx, input); err != nil {
+			return err
+		}
+		result.Created = append(result.Created, key)
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// SeedReviewer 种子模板的审核人标识
+const SeedReviewer = "system:seed"
+
+// seedTemplate 以草稿创建模板后直接置为种子指定的状态（仅支持 draft 与 enabled，enabled 视为已审核通过）
+func (s *Service) seedTemplate(ctx context.Context, input CreateTemplateInput) error {
+	status := input.Status
+	if status != model.TemplateStatusDraft && status != model.TemplateStatusEnabled {
+		return ErrInvalidInput.WithMsg("种子模板状态只能为 draft 或 enabled: " + string(status))
+	}
+
+	// 模板创建与状态变更在同一事务内完成，避免留下未启用的种子模板
+	return s.transaction(ctx, func(tx *Service) error {
+		input.Status = model.TemplateStatusDraft
+		template, err := tx.CreateTemplate(ctx, input)
+		if err != nil || status == model.TemplateStatusDraft {
+			return err
+		}
+
+		// 种子模板视为已提交并由 SeedReviewer 审核：按 pending_review 的流转规则校验目标状态
+		if !model.TemplateStatusPendingReview.CanTransitionTo(status) {
+			return ErrInvalidStatusTransition.WithMsg(fmt.Sprintf("种子模板状态不能从 %s 变更为 %s", model.TemplateStatusPendingReview, status))
+		}
+		before := *template
+		now := tx.clock.Now()
+		template.Status = status
+		template.ReviewedBy = SeedReviewer
+		template.ReviewedAt = &now
+		return tx.saveWithAudit(ctx, model.AuditActionApprove, &before, template)
+	})
+}
+
+// loadSeedTemplate 读取种子文件及其引用的正文文件
+func loadSeedTemplate(fsys fs.FS, p string) (*SeedTemplate, error) {
+	data, err := fs.ReadFile(fsys, p)
+	if err != nil {
+		return nil, err
+	}
+
+	var seed SeedTemplate
+	if err := json.Unmarshal(data, &seed); err != nil {
+		return nil, ErrInvalidInput.WithMsg("种子模板格式错误: " + p).Wrap(err)
+	}
+
+	dir := path.Dir(p)
+	if seed.BodyHTMLFile != "" {
+		body, err := fs.ReadFile(fsys, path.Join(dir, seed.BodyHTMLFile))
+		if err != nil {
+			return nil, err
+		}
+		seed.BodyHTML = string(body)
+	}
+	if seed.BodyTextFile != "" {
+		body, err := fs.ReadFile(fsys, path.Join(dir, seed.BodyTextFile))
+		if err != nil {
+			return nil, err
+		}
+		seed.BodyText = string(body)
+	}
+	return &seed, nil
+}
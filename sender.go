@@ -0,0 +1,157 @@
+package email_notification
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	email "github.com/KOMKZ/go-yogan-component-email"
+)
+
+// Message 渲染完成的待发送邮件
+type Message struct {
+	From        string       `json:"from"`
+	FromName    string       `json:"from_name"`
+	To          []string     `json:"to"`
+	Cc          []string     `json:"cc"`
+	Bcc         []string     `json:"bcc"`
+	ReplyTo     string       `json:"reply_to"`
+	Subject     string       `json:"subject"`
+	HTMLBody    string       `json:"html_body"`
+	TextBody    string       `json:"text_body"`
+	Attachments []Attachment `json:"-"`
+}
+
+// Sender 邮件发送器，返回服务商消息 ID
+type Sender interface {
+	Send(ctx context.Context, msg *Message) (messageID string, err error)
+}
+
+// ============ email.Manager 适配 ============
+
+type managerSender struct {
+	mgr *email.Manager
+}
+
+// NewManagerSender 基于 go-yogan-component-email 的发送器
+// 纯文本正文依赖组件 Builder 的 TextBody 方法，消息 ID 取自发送结果的 MessageID；组件未提供时分别只发送 HTML 正文、返回空消息 ID
+func NewManagerSender(mgr *email.Manager) Sender {
+	return &managerSender{mgr: mgr}
+}
+
+func (s *managerSender) Send(ctx context.Context, msg *Message) (string, error) {
+	if s.mgr == nil {
+		return "", ErrServiceNotAvailable.WithMsg("邮件组件未配置")
+	}
+
+	builder := s.mgr.New().
+		Subject(msg.Subject).
+		Body(msg.HTMLBody)
+	setTextBody(builder, msg.TextBody)
+
+	for _, to := range msg.To {
+		builder.To(to)
+	}
+
+	if msg.From != "" {
+		builder.From(msg.From)
+	}
+	if msg.FromName != "" {
+		builder.FromName(msg.FromName)
+	}
+	for _, cc := range msg.Cc {
+		builder.Cc(cc)
+	}
+	for _, bcc := range msg.Bcc {
+		builder.Bcc(bcc)
+	}
+	if msg.ReplyTo != "" {
+		builder.ReplyTo(msg.ReplyTo)
+	}
+	for _, att := range msg.Attachments {
+		builder.AttachWithType(att.Filename, att.Content, att.ContentType)
+	}
+
+	result, err := builder.Send(ctx)
+	if err != nil {
+		return "", err
+	}
+	return messageIDOf(result), nil
+}
+
+// ============ 内存记录发送器（测试用） ============
+
+// RecordingSender 记录所有发送的邮件，不实际投递
+type RecordingSender struct {
+	mu       sync.Mutex
+	messages []*Message
+	failFunc func(msg *Message) error
+}
+
+// NewRecordingSender 创建内存记录发送器
+func NewRecordingSender() *RecordingSender {
+	return &RecordingSender{}
+}
+
+// FailWith 设置失败规则（返回非 nil 错误时该邮件发送失败且不记录）
+func (s *RecordingSender) FailWith(fn func(msg *Message) error) {
+	s.mu.Lock()
+	s.failFunc = fn
+	s.mu.Unlock()
+}
+
+// Send 记录邮件
+func (s *RecordingSender) Send(ctx context.Context, msg *Message) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failFunc != nil {
+		if err := s.failFunc(msg); err != nil {
+			return "", err
+		}
+	}
+
+	s.messages = append(s.messages, msg)
+	return fmt.Sprintf("recording-%d", len(s.messages)), nil
+}
+
+// Messages 获取已记录的邮件
+func (s *RecordingSender) Messages() []*Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]*Message, len(s.messages))
+	copy(result, s.messages)
+	return result
+}
+
+// Last 获取最后一封邮件（无记录时返回 nil）
+func (s *RecordingSender) Last() *Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.messages) == 0 {
+		return nil
+	}
+	return s.messages[len(s.messages)-1]
+}
+
+// Reset 清空记录
+func (s *RecordingSender) Reset() {
+	s.mu.Lock()
+	s.messages = nil
+	s.mu.Unlock()
+}
+
+// splitAddresses 拆分逗号分隔的地址配置
+func splitAddresses(s string) []string {
+	var result []string
+	for _, addr := range strings.Split(s, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr != "" {
+			result = append(result, addr)
+		}
+	}
+	return result
+}
@@ -0,0 +1,42 @@
+package email_notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRecordingSender(t *testing.T) {
+	sender := NewRecordingSender()
+	ctx := context.Background()
+
+	id, err := sender.Send(ctx, &Message{To: []string{"a@example.com"}, Subject: "Hello"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id == "" {
+		t.Error("expected message id")
+	}
+
+	sender.FailWith(func(msg *Message) error {
+		if msg.To[0] == "bad@example.com" {
+			return errors.New("550 mailbox unavailable")
+		}
+		return nil
+	})
+	if _, err := sender.Send(ctx, &Message{To: []string{"bad@example.com"}}); err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	if len(sender.Messages()) != 1 {
+		t.Errorf("expected 1 recorded message, got %d", len(sender.Messages()))
+	}
+	if sender.Last().Subject != "Hello" {
+		t.Errorf("expected subject 'Hello', got '%s'", sender.Last().Subject)
+	}
+
+	sender.Reset()
+	if sender.Last() != nil {
+		t.Error("expected no messages after reset")
+	}
+}
@@ -3,6 +3,12 @@ package email_notification
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -13,26 +19,152 @@ import (
 
 // Service 邮件通知服务
 type Service struct {
-	db           *gorm.DB
-	templateRepo TemplateRepository
-	logRepo      SendLogRepository
-	emailMgr     *email.Manager
-	registry     *TriggerRegistry
-	engine       *TemplateEngine
-	commonParams map[string]any // 通用参数（应用级注入，Send 时自动合并）
+	db            *gorm.DB
+	templateRepo  TemplateRepository
+	versionRepo   TemplateVersionRepository
+	auditRepo     TemplateAuditRepository
+	partialRepo   PartialRepository
+	logRepo       SendLogRepository
+	jobRepo       SendJobRepository
+	sender        Sender
+	registry      *TriggerRegistry
+	engine        *TemplateEngine
+	commonParams  map[string]any // 通用参数（应用级注入，Send 时自动合并）
+	retryPolicy   RetryPolicy    // 默认重试策略（触发点可单独覆盖）
+	clock         Clock
+	logger        *slog.Logger
+	actorResolver func(ctx context.Context) string // 操作人解析（审计、版本作者、审核人）
+	templateCache *templateCacheConfig             // 模板与片段缓存配置（nil 表示不缓存）
+	partialCache  *lruCache[string, *partialSet]   // 语言 → 布局与片段
+	inTx          bool                             // 是否绑定调用方事务（WithTx）
+
+	defaultLanguage   string   // 默认语言（未指定语言时使用，也是回退链的终点）
+	languageFallbacks []string // 请求语言及其截断之后、默认语言之前依次尝试的语言
+
+	idempotencyWindow time.Duration // 幂等键有效期（<= 0 表示永久有效）
 }
 
+// DefaultIdempotencyWindow 默认幂等键有效期
+const DefaultIdempotencyWindow = 24 * time.Hour
+
 // NewService 创建服务
-func NewService(db *gorm.DB, emailMgr *email.Manager, registry *TriggerRegistry, commonParams map[string]any) *Service {
-	return &Service{
-		db:           db,
-		templateRepo: NewGormTemplateRepository(db),
-		logRepo:      NewGormSendLogRepository(db),
-		emailMgr:     emailMgr,
-		registry:     registry,
-		engine:       NewTemplateEngine(),
-		commonParams: commonParams,
+// 默认使用 GORM 仓储与 email.Manager 发送，可通过 Option 替换
+// 默认发送器见 NewManagerSender：邮件组件不支持纯文本正文或消息 ID 时，仅发送 HTML 正文且日志中消息 ID 为空
+func NewService(db *gorm.DB, emailMgr *email.Manager, registry *TriggerRegistry, commonParams map[string]any, opts ...Option) *Service {
+	s := &Service{
+		db:            db,
+		sender:        NewManagerSender(emailMgr),
+		registry:      registry,
+		commonParams:  commonParams,
+		retryPolicy:   DefaultRetryPolicy,
+		clock:         systemClock{},
+		logger:        slog.New(slog.DiscardHandler),
+		actorResolver: ActorFromContext,
+
+		defaultLanguage:   DefaultLanguage,
+		idempotencyWindow: DefaultIdempotencyWindow,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.engine == nil {
+		s.engine = NewTemplateEngine(WithNow(s.clock.Now))
+	}
+	if s.templateRepo == nil {
+		s.templateRepo = NewGormTemplateRepository(db)
+	}
+	if s.versionRepo == nil {
+		s.versionRepo = NewGormTemplateVersionRepository(db)
+	}
+	if s.auditRepo == nil {
+		s.auditRepo = NewGormTemplateAuditRepository(db)
+	}
+	if s.partialRepo == nil {
+		s.partialRepo = NewGormPartialRepository(db)
+	}
+	if s.logRepo == nil {
+		s.logRepo = NewGormSendLogRepository(db)
+	}
+	if s.jobRepo == nil {
+		s.jobRepo = NewGormSendJobRepository(db)
+	}
+
+	if c := s.templateCache; c != nil {
+		s.templateRepo = NewCachingTemplateRepository(s.templateRepo, c.size, c.ttl)
+		s.partialCache = newLRUCache[string, *partialSet](c.size, c.ttl)
+	}
+	return s
+}
+
+// SetSender 替换邮件发送器（如测试中使用 RecordingSender）
+func (s *Service) SetSender(sender Sender) {
+	s.sender = sender
+}
+
+// SetRetryPolicy 设置默认重试策略
+func (s *Service) SetRetryPolicy(policy RetryPolicy) {
+	s.retryPolicy = policy
+}
+
+// WithTx 返回绑定调用方事务的服务副本（事务性发件箱）
+// 副本的 Send 与 SendAsync 均只在事务内写入日志和队列任务，事务提交后由 Worker 投递；事务回滚则邮件不会发出
+// 启用模板缓存时，副本的模板写操作无法在调用方提交后清空缓存，模板管理请直接使用原服务
+func (s *Service) WithTx(tx *gorm.DB) *Service {
+	clone := *s
+	clone.db = tx
+	clone.inTx = true
+
+	// 仅默认 GORM 仓储可绑定事务，自定义仓储保持原样
+	switch repo := s.templateRepo.(type) {
+	case *gormTemplateRepository:
+		clone.templateRepo = NewGormTemplateRepository(tx)
+	case *cachingTemplateRepository:
+		if _, ok := repo.next.(*gormTemplateRepository); ok {
+			clone.templateRepo = repo.withNext(NewGormTemplateRepository(tx))
+		}
+	}
+	if _, ok := s.versionRepo.(*gormTemplateVersionRepository); ok {
+		clone.versionRepo = NewGormTemplateVersionRepository(tx)
+	}
+	if _, ok := s.auditRepo.(*gormTemplateAuditRepository); ok {
+		clone.auditRepo = NewGormTemplateAuditRepository(tx)
+	}
+	if _, ok := s.partialRepo.(*gormPartialRepository); ok {
+		clone.partialRepo = NewGormPartialRepository(tx)
+	}
+	if _, ok := s.logRepo.(*gormSendLogRepository); ok {
+		clone.logRepo = NewGormSendLogRepository(tx)
 	}
+	if _, ok := s.jobRepo.(*gormSendJobRepository); ok {
+		clone.jobRepo = NewGormSendJobRepository(tx)
+	}
+	return &clone
+}
+
+// transaction 在事务内执行 fn（未配置 db 时直接执行，如内存仓储）
+// 事务结束后清空模板缓存：事务内清空后、提交前，并发读取可能已将旧数据重新写入缓存
+func (s *Service) transaction(ctx context.Context, fn func(tx *Service) error) error {
+	if s.db == nil {
+		return fn(s)
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(s.WithTx(tx))
+	})
+	if repo, ok := s.templateRepo.(*cachingTemplateRepository); ok && !s.inTx {
+		repo.Purge()
+	}
+	return err
+}
+
+// actor 当前操作人
+func (s *Service) actor(ctx context.Context) string {
+	return s.actorResolver(ctx)
+}
+
+// EnqueueInTx 在调用方事务内将邮件写入发送队列
+func (s *Service) EnqueueInTx(ctx context.Context, tx *gorm.DB, input SendInput) error {
+	return s.WithTx(tx).SendAsync(ctx, input)
 }
 
 // ========== Trigger 查询 ==========
@@ -70,10 +202,12 @@ func (s *Service) CreateTemplate(ctx context.Context, input CreateTemplateInput)
 		return nil, ErrTriggerNotFound.WithMsg("触发点不存在: " + input.TriggerCode)
 	}
 
-	// 设置默认语言
-	if input.Language == "" {
-		input.Language = "zh-CN"
+	// 规范化语言并设置默认语言
+	language, err := s.normalizeLanguage(input.Language)
+	if err != nil {
+		return nil, err
 	}
+	input.Language = language
 
 	// 检查是否已存在
 	exists, err := s.templateRepo.ExistsByTriggerAndLanguage(ctx, input.TriggerCode, input.Language, 0)
@@ -84,10 +218,19 @@ func (s *Service) CreateTemplate(ctx context.Context, input CreateTemplateInput)
 		return nil, ErrTemplateExists
 	}
 
-	// 设置默认状态
+	// 新模板一律为草稿，需提交审核通过后才能启用
 	if input.Status == "" {
 		input.Status = model.TemplateStatusDraft
 	}
+	if input.Status != model.TemplateStatusDraft {
+		return nil, ErrTemplateReviewRequired.WithMsg("新模板须提交审核，审核通过后启用")
+	}
+	if err := s.checkLayout(ctx, input.Layout, input.Language); err != nil {
+		return nil, err
+	}
+	if err := s.lintTemplate(ctx, input); err != nil {
+		return nil, err
+	}
 
 	template := &model.Template{
 		TriggerCode: input.TriggerCode,
@@ -100,21 +243,53 @@ func (s *Service) CreateTemplate(ctx context.Context, input CreateTemplateInput)
 		Cc:          input.Cc,
 		Bcc:         input.Bcc,
 		ReplyTo:     input.ReplyTo,
+		Layout:      input.Layout,
 	}
 
-	if err := s.templateRepo.Create(ctx, template); err != nil {
-		return nil, ErrDatabaseError.Wrap(err)
+	// 模板与首个版本在同一事务内创建，首个版本直接发布
+	err = s.transaction(ctx, func(tx *Service) error {
+		if err := tx.templateRepo.Create(ctx, template); err != nil {
+			return err
+		}
+		if err := tx.createVersion(ctx, template, "创建模板", true); err != nil {
+			return err
+		}
+		if err := tx.templateRepo.Update(ctx, template); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, model.AuditActionCreate, nil, template)
+	})
+	if err != nil {
+		return nil, templateWriteError(err)
 	}
 
 	return template, nil
 }
 
 // UpdateTemplate 更新模板
+// 内容字段（主题、正文、抄送等）变更时记录新版本；input.Publish 为 false 时仅保存为草稿修订，线上发送仍使用已发布版本
+// 状态变更需符合状态机，提交审核与审核结果须通过 SubmitForReview / Approve / Reject；仅草稿可直接发布修订
 func (s *Service) UpdateTemplate(ctx context.Context, id uint, input UpdateTemplateInput) (*model.Template, error) {
 	template, err := s.templateRepo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
+	if input.Revision != nil && *input.Revision != template.Revision {
+		return nil, ErrTemplateConflict
+	}
+	before := *template
+
+	switch template.Status {
+	case model.TemplateStatusArchived:
+		return nil, ErrInvalidStatusTransition.WithMsg("已归档的模板不可修改")
+	case model.TemplateStatusPendingReview:
+		return nil, ErrInvalidStatusTransition.WithMsg("审核中的模板不可修改，请等待审核结果")
+	}
+	if input.Status != nil && *input.Status != template.Status {
+		if err := checkManualTransition(template.Status, *input.Status); err != nil {
+			return nil, err
+		}
+	}
 
 	if input.Name != nil {
 		template.Name = *input.Name
@@ -140,17 +315,113 @@ func (s *Service) UpdateTemplate(ctx context.Context, id uint, input UpdateTempl
 	if input.ReplyTo != nil {
 		template.ReplyTo = *input.ReplyTo
 	}
+	if input.Layout != nil && *input.Layout != template.Layout {
+		if err := s.checkLayout(ctx, *input.Layout, template.Language); err != nil {
+			return nil, err
+		}
+		template.Layout = *input.Layout
+	}
 
-	if err := s.templateRepo.Update(ctx, template); err != nil {
-		return nil, ErrDatabaseError.Wrap(err)
+	// 主题或正文变更时做静态检查
+	if template.Subject != before.Subject || template.BodyHTML != before.BodyHTML || template.BodyText != before.BodyText {
+		err := s.lintTemplate(ctx, CreateTemplateInput{
+			TriggerCode: template.TriggerCode,
+			Language:    template.Language,
+			Subject:     template.Subject,
+			BodyHTML:    template.BodyHTML,
+			BodyText:    template.BodyText,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if input.Publish && template.Status != model.TemplateStatusDraft {
+		return nil, ErrTemplateReviewRequired.WithMsg("仅草稿模板可直接发布修订，其余状态请退回草稿并提交审核")
+	}
+
+	err = s.transaction(ctx, func(tx *Service) error {
+		contentChanged := !model.NewTemplateVersion(&before, 0, "", "").SameContent(template)
+
+		// 无版本记录的历史模板：先将原内容记为已发布的版本 1，保证可回滚
+		if contentChanged && template.LatestVersion == 0 {
+			if err := tx.createVersion(ctx, &before, "初始版本", true); err != nil {
+				return err
+			}
+			template.LatestVersion = before.LatestVersion
+			template.PublishedVersion = before.PublishedVersion
+		}
+
+		if contentChanged {
+			if err := tx.createVersion(ctx, template, input.ChangeNote, input.Publish); err != nil {
+				return err
+			}
+		}
+		if err := tx.templateRepo.Update(ctx, template); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, model.AuditActionUpdate, &before, template)
+	})
+	if err != nil {
+		return nil, templateWriteError(err)
 	}
 
 	return template, nil
 }
 
+// templateWriteError 模板写入错误：唯一冲突、并发冲突与不存在原样返回，其余包装为数据库错误
+func templateWriteError(err error) error {
+	if errors.Is(err, ErrTemplateExists) || errors.Is(err, ErrTemplateConflict) || errors.Is(err, ErrTemplateNotFound) {
+		return err
+	}
+	return ErrDatabaseError.Wrap(err)
+}
+
+// checkManualTransition 校验通过 UpdateTemplate 直接修改状态是否允许
+func checkManualTransition(from, to model.TemplateStatus) error {
+	if !to.IsValid() {
+		return ErrInvalidInput.WithMsg("未知的模板状态: " + string(to))
+	}
+	if to == model.TemplateStatusPendingReview {
+		return ErrInvalidStatusTransition.WithMsg("请通过提交审核接口提交模板")
+	}
+	if from == model.TemplateStatusDraft && to == model.TemplateStatusEnabled {
+		return ErrTemplateReviewRequired
+	}
+	if !from.CanTransitionTo(to) {
+		return ErrInvalidStatusTransition.WithMsg(fmt.Sprintf("模板状态不能从 %s 变更为 %s", from, to))
+	}
+	return nil
+}
+
+// createVersion 以模板当前内容创建新版本，并更新模板的版本指针（由调用方保存模板）
+func (s *Service) createVersion(ctx context.Context, template *model.Template, changeNote string, publish bool) error {
+	version := model.NewTemplateVersion(template, template.LatestVersion+1, s.actor(ctx), changeNote)
+	version.CreatedAt = s.clock.Now()
+	if err := s.versionRepo.Create(ctx, version); err != nil {
+		return err
+	}
+
+	template.LatestVersion = version.Version
+	if publish {
+		template.PublishedVersion = version.Version
+	}
+	return nil
+}
+
 // DeleteTemplate 删除模板
 func (s *Service) DeleteTemplate(ctx context.Context, id uint) error {
-	return s.templateRepo.Delete(ctx, id)
+	template, err := s.templateRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return s.transaction(ctx, func(tx *Service) error {
+		if err := tx.templateRepo.Delete(ctx, id); err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, model.AuditActionDelete, template, nil)
+	})
 }
 
 // GetTemplate 获取模板详情
@@ -168,236 +439,1243 @@ func (s *Service) GetTemplateByTrigger(ctx context.Context, triggerCode, languag
 	return s.templateRepo.GetActiveTemplate(ctx, triggerCode, language)
 }
 
-// ========== 预览与测试 ==========
+// ========== 模板版本 ==========
 
-// PreviewTemplate 预览模板
-func (s *Service) PreviewTemplate(ctx context.Context, id uint) (*PreviewResult, error) {
-	template, err := s.templateRepo.GetByID(ctx, id)
-	if err != nil {
+// ListTemplateVersions 获取模板的修订历史（按版本号倒序）
+func (s *Service) ListTemplateVersions(ctx context.Context, templateID uint) ([]model.TemplateVersion, error) {
+	if _, err := s.templateRepo.GetByID(ctx, templateID); err != nil {
 		return nil, err
 	}
+	return s.versionRepo.ListByTemplate(ctx, templateID)
+}
 
-	params := s.registry.GetAllParams(template.TriggerCode)
+// GetTemplateVersion 获取模板的指定版本
+func (s *Service) GetTemplateVersion(ctx context.Context, templateID uint, version int) (*model.TemplateVersion, error) {
+	return s.versionRepo.Get(ctx, templateID, version)
+}
 
-	subject, err := s.engine.Preview(template.Subject, params)
+// PublishVersion 发布指定版本（线上发送改用该版本内容，模板编辑内容不变）
+func (s *Service) PublishVersion(ctx context.Context, templateID uint, version int) (*model.Template, error) {
+	template, err := s.templateRepo.GetByID(ctx, templateID)
 	if err != nil {
 		return nil, err
 	}
-
-	bodyHTML, err := s.engine.Preview(template.BodyHTML, params)
-	if err != nil {
+	if template.Status != model.TemplateStatusDraft {
+		return nil, ErrTemplateReviewRequired.WithMsg("仅草稿模板可直接发布版本，其余状态请退回草稿并提交审核")
+	}
+	if _, err := s.versionRepo.Get(ctx, templateID, version); err != nil {
 		return nil, err
 	}
 
-	bodyText := ""
-	if template.BodyText != "" {
-		bodyText, err = s.engine.Preview(template.BodyText, params)
-		if err != nil {
-			return nil, err
-		}
-	}
+	before := *template
+	template.PublishedVersion = version
 
-	return &PreviewResult{
-		Subject:  subject,
-		BodyHTML: bodyHTML,
-		BodyText: bodyText,
-	}, nil
+	if err := s.saveWithAudit(ctx, model.AuditActionPublish, &before, template); err != nil {
+		return nil, err
+	}
+	return template, nil
 }
 
-// TestSend 测试发送
-func (s *Service) TestSend(ctx context.Context, id uint, recipient string) error {
-	if recipient == "" {
-		return ErrNoRecipient
+// RollbackTemplate 回滚到指定版本：以该版本内容创建新版本并立即发布，模板编辑内容同步恢复
+func (s *Service) RollbackTemplate(ctx context.Context, templateID uint, version int, changeNote string) (*model.Template, error) {
+	template, err := s.templateRepo.GetByID(ctx, templateID)
+	if err != nil {
+		return nil, err
 	}
-
-	template, err := s.templateRepo.GetByID(ctx, id)
+	if template.Status != model.TemplateStatusDraft {
+		return nil, ErrTemplateReviewRequired.WithMsg("仅草稿模板可直接回滚，其余状态请退回草稿并提交审核")
+	}
+	target, err := s.versionRepo.Get(ctx, templateID, version)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	params := s.registry.GetAllParams(template.TriggerCode)
+	if changeNote == "" {
+		changeNote = fmt.Sprintf("回滚到版本 %d", version)
+	}
+	before := *template
+	target.ApplyTo(template)
 
-	// 使用示例值渲染
-	exampleParams := make(map[string]any)
-	for _, p := range params {
-		if p.Example != "" {
-			exampleParams[p.Name] = p.Example
-		} else {
-			exampleParams[p.Name] = "{{." + p.Name + "}}"
+	err = s.transaction(ctx, func(tx *Service) error {
+		if err := tx.createVersion(ctx, template, changeNote, true); err != nil {
+			return err
+		}
+		if err := tx.templateRepo.Update(ctx, template); err != nil {
+			return err
 		}
+		return tx.recordAudit(ctx, model.AuditActionRollback, &before, template)
+	})
+	if err != nil {
+		return nil, templateWriteError(err)
 	}
-	exampleParams["CurrentYear"] = time.Now().Year()
-
-	return s.sendWithTemplate(ctx, template, recipient, exampleParams, nil)
+	return template, nil
 }
 
-// ========== 发送 ==========
+// ========== 模板审核 ==========
 
-// Send 同步发送邮件
-func (s *Service) Send(ctx context.Context, input SendInput) error {
-	// 验证输入
-	if input.TriggerCode == "" {
-		return ErrInvalidInput.WithMsg("触发点代码不能为空")
+// SubmitForReview 提交模板审核（draft → pending_review），提交人取自 context 中的操作人
+// 审核期间模板不可修改，审核通过后发布最新修订
+func (s *Service) SubmitForReview(ctx context.Context, id uint) (*model.Template, error) {
+	template, err := s.templateRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
 	}
-	if input.Recipient == "" {
-		return ErrNoRecipient
+	if !template.Status.CanTransitionTo(model.TemplateStatusPendingReview) {
+		return nil, ErrInvalidStatusTransition.WithMsg("仅草稿模板可提交审核")
 	}
 
-	// 验证触发点存在
-	if !s.registry.Exists(input.TriggerCode) {
-		return ErrTriggerNotFound.WithMsg("触发点不存在: " + input.TriggerCode)
-	}
+	before := *template
+	now := s.clock.Now()
+	template.Status = model.TemplateStatusPendingReview
+	template.SubmittedBy = s.actor(ctx)
+	template.SubmittedAt = &now
+	template.ReviewedBy = ""
+	template.ReviewedAt = nil
+	template.RejectReason = ""
 
-	// 确定语言
-	language := input.Language
-	if language == "" {
-		language = "zh-CN"
+	if err := s.saveWithAudit(ctx, model.AuditActionSubmit, &before, template); err != nil {
+		return nil, err
 	}
+	return template, nil
+}
 
-	// 获取启用的模板
-	template, err := s.templateRepo.GetActiveTemplate(ctx, input.TriggerCode, language)
+// Approve 审核通过（pending_review → enabled），上线草稿选定的发布版本（从未发布过时为最新修订）
+// 审核人取自 context 中的操作人，且不能为提交人
+func (s *Service) Approve(ctx context.Context, id uint) (*model.Template, error) {
+	template, reviewer, err := s.reviewableTemplate(ctx, id)
 	if err != nil {
-		// 尝试回退到默认语言
-		if language != "zh-CN" {
-			template, err = s.templateRepo.GetActiveTemplate(ctx, input.TriggerCode, "zh-CN")
+		return nil, err
+	}
+
+	before := *template
+	now := s.clock.Now()
+	template.Status = model.TemplateStatusEnabled
+	template.ReviewedBy = reviewer
+	template.ReviewedAt = &now
+	template.RejectReason = ""
+
+	err = s.transaction(ctx, func(tx *Service) error {
+		// 无版本记录的历史模板：先记录当前内容，保证上线内容可追溯
+		if template.LatestVersion == 0 {
+			if err := tx.createVersion(ctx, template, "审核通过", true); err != nil {
+				return err
+			}
 		}
-		if err != nil {
+		// 上线草稿选定的发布版本（PublishVersion、RollbackTemplate 或带 Publish 的修改），从未发布过时上线最新版本
+		if template.PublishedVersion == 0 {
+			template.PublishedVersion = template.LatestVersion
+		}
+		if err := tx.templateRepo.Update(ctx, template); err != nil {
 			return err
 		}
+		return tx.recordAudit(ctx, model.AuditActionApprove, &before, template)
+	})
+	if err != nil {
+		return nil, templateWriteError(err)
 	}
+	return template, nil
+}
 
-	// 合并参数
-	params := s.mergeParams(input.Params)
+// Reject 驳回审核（pending_review → draft），须填写驳回原因
+func (s *Service) Reject(ctx context.Context, id uint, reason string) (*model.Template, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrInvalidInput.WithMsg("驳回原因不能为空")
+	}
 
-	return s.sendWithTemplate(ctx, template, input.Recipient, params, &input)
-}
+	template, reviewer, err := s.reviewableTemplate(ctx, id)
+	if err != nil {
+		return nil, err
+	}
 
-// SendAsync 异步发送邮件（预留，暂不实现）
-func (s *Service) SendAsync(ctx context.Context, input SendInput) error {
-	return ErrNotImplemented.WithMsg("异步发送暂未实现")
+	before := *template
+	now := s.clock.Now()
+	template.Status = model.TemplateStatusDraft
+	template.ReviewedBy = reviewer
+	template.ReviewedAt = &now
+	template.RejectReason = reason
+
+	if err := s.saveWithAudit(ctx, model.AuditActionReject, &before, template); err != nil {
+		return nil, err
+	}
+	return template, nil
 }
 
-// sendWithTemplate 使用模板发送邮件
-func (s *Service) sendWithTemplate(ctx context.Context, template *model.Template, recipient string, params map[string]any, input *SendInput) error {
-	// 渲染主题
-	subject, err := s.engine.Render(template.Subject, params)
-	if err != nil {
-		return err
+// reviewableTemplate 获取待审核模板并校验审核人身份
+func (s *Service) reviewableTemplate(ctx context.Context, id uint) (*model.Template, string, error) {
+	reviewer := s.actor(ctx)
+	if reviewer == "" {
+		return nil, "", ErrReviewerRequired
 	}
 
-	// 渲染正文
-	body, err := s.engine.Render(template.BodyHTML, params)
+	template, err := s.templateRepo.GetByID(ctx, id)
 	if err != nil {
-		return err
+		return nil, "", err
 	}
-
-	// 创建发送日志
-	paramsJSON, _ := json.Marshal(params)
-	sendLog := &model.SendLog{
-		TemplateID:  &template.ID,
-		TriggerCode: template.TriggerCode,
-		Language:    template.Language,
-		Recipient:   recipient,
-		Subject:     subject,
-		Params:      string(paramsJSON),
-		Status:      model.SendStatusPending,
+	if template.Status != model.TemplateStatusPendingReview {
+		return nil, "", ErrInvalidStatusTransition.WithMsg("模板不在审核中")
 	}
-	if err := s.logRepo.Create(ctx, sendLog); err != nil {
-		return ErrDatabaseError.Wrap(err)
+	if template.SubmittedBy != "" && template.SubmittedBy == reviewer {
+		return nil, "", ErrSelfReview
 	}
+	return template, reviewer, nil
+}
 
-	// 构建邮件
-	builder := s.emailMgr.New().
-		To(recipient).
-		Subject(subject).
-		Body(body)
+// ========== 审计 ==========
 
-	// 发件人
-	if input != nil && input.From != "" {
-		builder.From(input.From)
-	}
-	if input != nil && input.FromName != "" {
-		builder.FromName(input.FromName)
-	}
+// ListTemplateAudit 获取模板的变更审计记录（按时间倒序，已删除模板仍可查询）
+func (s *Service) ListTemplateAudit(ctx context.Context, templateID uint) ([]model.TemplateAudit, error) {
+	return s.auditRepo.ListByTemplate(ctx, templateID)
+}
 
-	// 抄送：模板配置 + input 追加
-	if template.Cc != "" {
-		for _, cc := range strings.Split(template.Cc, ",") {
-			cc = strings.TrimSpace(cc)
-			if cc != "" {
-				builder.Cc(cc)
-			}
+// saveWithAudit 在同一事务内保存模板并记录审计
+func (s *Service) saveWithAudit(ctx context.Context, action model.TemplateAuditAction, before, after *model.Template) error {
+	err := s.transaction(ctx, func(tx *Service) error {
+		if err := tx.templateRepo.Update(ctx, after); err != nil {
+			return err
 		}
+		return tx.recordAudit(ctx, action, before, after)
+	})
+	if err != nil {
+		return templateWriteError(err)
 	}
-	if input != nil {
-		for _, cc := range input.Cc {
-			builder.Cc(cc)
-		}
+	return nil
+}
+
+// recordAudit 记录模板变更审计（before 为 nil 表示新建，after 为 nil 表示删除），须在变更所在事务内调用
+func (s *Service) recordAudit(ctx context.Context, action model.TemplateAuditAction, before, after *model.Template) error {
+	target := after
+	if target == nil {
+		target = before
 	}
 
-	// 密送：模板配置 + input 追加
-	if template.Bcc != "" {
-		for _, bcc := range strings.Split(template.Bcc, ",") {
-			bcc = strings.TrimSpace(bcc)
-			if bcc != "" {
-				builder.Bcc(bcc)
-			}
-		}
+	audit := &model.TemplateAudit{
+		TemplateID: target.ID,
+		Action:     action,
+		Actor:      s.actor(ctx),
+		CreatedAt:  s.clock.Now(),
 	}
-	if input != nil {
-		for _, bcc := range input.Bcc {
-			builder.Bcc(bcc)
-		}
+	audit.SetChanges(model.DiffTemplates(before, after))
+	return s.auditRepo.Create(ctx, audit)
+}
+
+// ========== 布局与片段 ==========
+
+// partialNamePattern 布局与片段名称规则
+var partialNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]*$`)
+
+// CreatePartial 创建布局或片段
+func (s *Service) CreatePartial(ctx context.Context, input CreatePartialInput) (*model.Partial, error) {
+	if !partialNamePattern.MatchString(input.Name) || input.Name == rootTemplateName {
+		return nil, ErrInvalidInput.WithMsg("名称须以字母开头，仅包含字母、数字、_ . -，且不能为 " + rootTemplateName)
+	}
+	language, err := s.normalizeLanguage(input.Language)
+	if err != nil {
+		return nil, err
+	}
+	input.Language = language
+	if input.Kind == "" {
+		input.Kind = model.PartialKindPartial
+	}
+	if input.Kind != model.PartialKindPartial && input.Kind != model.PartialKindLayout {
+		return nil, ErrInvalidInput.WithMsg("未知的类型: " + string(input.Kind))
 	}
 
-	// 回复地址
-	replyTo := ""
-	if input != nil && input.ReplyTo != "" {
-		replyTo = input.ReplyTo
-	} else if template.ReplyTo != "" {
-		replyTo = template.ReplyTo
+	partial := &model.Partial{
+		Name:        input.Name,
+		Language:    input.Language,
+		Kind:        input.Kind,
+		Description: input.Description,
+		BodyHTML:    input.BodyHTML,
+		BodyText:    input.BodyText,
 	}
-	if replyTo != "" {
-		builder.ReplyTo(replyTo)
+	if err := s.validatePartial(ctx, partial); err != nil {
+		return nil, err
 	}
 
-	// 附件
-	if input != nil {
-		for _, att := range input.Attachments {
-			builder.AttachWithType(att.Filename, att.Content, att.ContentType)
+	if err := s.partialRepo.Create(ctx, partial); err != nil {
+		if errors.Is(err, ErrPartialExists) {
+			return nil, err
 		}
+		return nil, ErrDatabaseError.Wrap(err)
 	}
+	s.invalidatePartials()
+	return partial, nil
+}
 
-	// 发送
-	_, sendErr := builder.Send(ctx)
+// UpdatePartial 更新布局或片段（名称、语言与类型不可修改）
+func (s *Service) UpdatePartial(ctx context.Context, id uint, input UpdatePartialInput) (*model.Partial, error) {
+	partial, err := s.partialRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
 
-	// 更新日志
-	if sendErr != nil {
-		sendLog.MarkFailed(sendErr.Error())
-	} else {
-		sendLog.MarkSent()
+	if input.Description != nil {
+		partial.Description = *input.Description
+	}
+	if input.BodyHTML != nil {
+		partial.BodyHTML = *input.BodyHTML
+	}
+	if input.BodyText != nil {
+		partial.BodyText = *input.BodyText
+	}
+	if err := s.validatePartial(ctx, partial); err != nil {
+		return nil, err
 	}
-	s.logRepo.Update(ctx, sendLog)
 
-	if sendErr != nil {
-		return ErrSendFailed.Wrap(sendErr)
+	if err := s.partialRepo.Update(ctx, partial); err != nil {
+		return nil, ErrDatabaseError.Wrap(err)
 	}
+	s.invalidatePartials()
+	return partial, nil
+}
 
+// DeletePartial 删除布局或片段
+func (s *Service) DeletePartial(ctx context.Context, id uint) error {
+	partial, err := s.partialRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.checkPartialUnused(ctx, partial); err != nil {
+		return err
+	}
+	if err := s.partialRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.invalidatePartials()
 	return nil
 }
 
-// mergeParams 合并参数
-func (s *Service) mergeParams(params map[string]any) map[string]any {
-	merged := make(map[string]any)
-
-	// 1. 注入通用参数（应用级）
-	for k, v := range s.commonParams {
-		merged[k] = v
+// checkPartialUnused 删除前检查：删除后仍有模板（当前内容或已发布版本）或片段引用该名称且无法按语言回退解析时返回 ErrPartialInUse
+// 删除前已无法解析的引用不计入
+func (s *Service) checkPartialUnused(ctx context.Context, partial *model.Partial) error {
+	templates, err := s.allTemplates(ctx)
+	if err != nil {
+		return err
+	}
+	partials, err := s.partialRepo.List(ctx, PartialFilter{})
+	if err != nil {
+		return err
+	}
+
+	languages := make(map[string]bool)
+	for _, t := range templates {
+		languages[t.Language] = true
+	}
+	for _, p := range partials {
+		languages[p.Language] = true
+	}
+
+	var users []string
+	for language := range languages {
+		before, err := s.buildPartials(ctx, language, 0)
+		if err != nil {
+			return err
+		}
+		after, err := s.buildPartials(ctx, language, partial.ID)
+		if err != nil {
+			return err
+		}
+		if before.Digest == after.Digest {
+			continue // 该语言下不使用被删除的版本
+		}
+
+		for i := range templates {
+			t := &templates[i]
+			if t.Language != language || t.Status == model.TemplateStatusArchived {
+				continue
+			}
+			published, err := s.publishedTemplate(ctx, t)
+			if err != nil {
+				return err
+			}
+			for _, content := range []*model.Template{t, published} {
+				if breaksTemplate(content, before, after) {
+					users = append(users, fmt.Sprintf("模板 #%d（%s/%s）", t.ID, t.TriggerCode, t.Language))
+					break
+				}
+			}
+		}
+		for _, p := range partials {
+			if p.Language != language || p.ID == partial.ID {
+				continue
+			}
+			ref := `{{template "` + p.Name + `" .}}`
+			if breaksSource(ref, before.HTML, after.HTML) || breaksSource(ref, before.Text, after.Text) {
+				users = append(users, fmt.Sprintf("片段 %s（%s）", p.Name, p.Language))
+			}
+		}
+	}
+
+	if len(users) > 0 {
+		sort.Strings(users)
+		return ErrPartialInUse.WithMsg(fmt.Sprintf("%s（%s）仍被引用: %s", partial.Name, partial.Language, strings.Join(users, "、")))
+	}
+	return nil
+}
+
+// breaksTemplate 删除片段后模板的布局或正文引用是否无法解析
+func breaksTemplate(t *model.Template, before, after *partialSet) bool {
+	if t.Layout != "" {
+		if _, ok := before.HTML[t.Layout]; ok {
+			if _, ok := after.HTML[t.Layout]; !ok {
+				return true
+			}
+		}
+	}
+	return breaksSource(t.BodyHTML, before.HTML, after.HTML) ||
+		(t.BodyText != "" && breaksSource(t.BodyText, before.Text, after.Text))
+}
+
+// breaksSource 删除前可解析、删除后引用缺失
+func breaksSource(src string, before, after map[string]string) bool {
+	if _, err := ResolvePartials(src, before); err != nil {
+		return false
+	}
+	_, err := ResolvePartials(src, after)
+	return errors.Is(err, ErrPartialNotFound)
+}
+
+// GetPartial 获取布局或片段
+func (s *Service) GetPartial(ctx context.Context, id uint) (*model.Partial, error) {
+	return s.partialRepo.GetByID(ctx, id)
+}
+
+// ListPartials 布局与片段列表
+func (s *Service) ListPartials(ctx context.Context, filter PartialFilter) ([]model.Partial, error) {
+	return s.partialRepo.List(ctx, filter)
+}
+
+// validatePartial 校验片段语法，并检查保存后是否形成循环引用
+func (s *Service) validatePartial(ctx context.Context, partial *model.Partial) error {
+	if err := s.engine.Validate(partial.BodyHTML); err != nil {
+		return ErrInvalidInput.WithMsg("HTML 内容解析失败").Wrap(err)
+	}
+	if err := s.engine.Validate(partial.BodyText); err != nil {
+		return ErrInvalidInput.WithMsg("纯文本内容解析失败").Wrap(err)
+	}
+
+	set, err := s.loadPartials(ctx, partial.Language)
+	if err != nil {
+		return err
+	}
+	set.HTML[partial.Name] = partial.BodyHTML
+	set.Text[partial.Name] = partial.BodyText
+	if err := CheckPartialCycle(partial.Name, set.HTML); err != nil {
+		return err
+	}
+	return CheckPartialCycle(partial.Name, set.Text)
+}
+
+// checkLayout 校验模板引用的布局存在（为空表示不使用布局）
+func (s *Service) checkLayout(ctx context.Context, name, language string) error {
+	if name == "" {
+		return nil
+	}
+
+	var (
+		partial *model.Partial
+		err     error
+	)
+	for _, lang := range s.languageChain(language) {
+		partial, err = s.partialRepo.GetByName(ctx, name, lang)
+		if !errors.Is(err, ErrPartialNotFound) {
+			break
+		}
+	}
+	if err != nil {
+		if errors.Is(err, ErrPartialNotFound) {
+			return ErrPartialNotFound.WithMsg("布局不存在: " + name)
+		}
+		return err
+	}
+	if !partial.IsLayout() {
+		return ErrInvalidInput.WithMsg(name + " 不是布局")
+	}
+	return nil
+}
+
+// partialSet 指定语言可用的布局与片段（名称 → 内容）
+type partialSet struct {
+	HTML   map[string]string
+	Text   map[string]string // 仅包含配置了纯文本版本的条目
+	Digest string            // 内容摘要（用于解析缓存键）
+}
+
+// partialsFor 获取指定语言可用的布局与片段（启用缓存时读缓存，结果不可修改）
+func (s *Service) partialsFor(ctx context.Context, language string) (*partialSet, error) {
+	if s.partialCache != nil {
+		if set, ok := s.partialCache.Get(language); ok {
+			return set, nil
+		}
+	}
+
+	set, err := s.loadPartials(ctx, language)
+	if err != nil {
+		return nil, err
+	}
+	if s.partialCache != nil {
+		s.partialCache.Add(language, set)
+	}
+	return set, nil
+}
+
+// loadPartials 从仓储加载指定语言可用的布局与片段，缺少该语言版本时按语言回退链回退
+func (s *Service) loadPartials(ctx context.Context, language string) (*partialSet, error) {
+	return s.buildPartials(ctx, language, 0)
+}
+
+// buildPartials 加载指定语言可用的布局与片段，跳过 ID 为 skipID 的片段（用于模拟删除）
+func (s *Service) buildPartials(ctx context.Context, language string, skipID uint) (*partialSet, error) {
+	chain := s.languageChain(language)
+
+	// 从回退链末端（默认语言）开始逐级覆盖
+	set := &partialSet{HTML: make(map[string]string), Text: make(map[string]string)}
+	for i := len(chain) - 1; i >= 0; i-- {
+		lang := chain[i]
+		items, err := s.partialRepo.List(ctx, PartialFilter{Language: lang})
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range items {
+			if p.ID == skipID && skipID != 0 {
+				continue
+			}
+			set.HTML[p.Name] = p.BodyHTML
+			if p.BodyText != "" {
+				set.Text[p.Name] = p.BodyText
+			} else {
+				delete(set.Text, p.Name)
+			}
+		}
+	}
+	set.Digest = digestPartials(set.HTML, set.Text)
+	return set, nil
+}
+
+// invalidatePartials 布局或片段变更后清空缓存
+func (s *Service) invalidatePartials() {
+	if s.partialCache != nil {
+		s.partialCache.Purge()
+	}
+}
+
+// ========== 预览与测试 ==========
+
+// PreviewTemplate 预览模板（使用示例值）
+func (s *Service) PreviewTemplate(ctx context.Context, id uint) (*PreviewResult, error) {
+	return s.PreviewTemplateWithParams(ctx, id, nil)
+}
+
+// PreviewTemplateWithParams 使用调用方参数预览模板的最新修订内容
+// 参数与发送时同样合并通用参数与 CurrentYear，未提供的参数使用示例值（无示例值时保留占位符）；预览不校验必填参数
+func (s *Service) PreviewTemplateWithParams(ctx context.Context, id uint, params map[string]any) (*PreviewResult, error) {
+	template, err := s.templateRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// 预览最新修订内容（即模板当前字段）
+	template.PublishedVersion = template.LatestVersion
+	return s.preview(ctx, template, params)
+}
+
+// PreviewDraft 预览未保存的模板（编辑器实时预览），按 input.Language 套用对应语言的布局、片段与格式化函数
+func (s *Service) PreviewDraft(ctx context.Context, input CreateTemplateInput, params map[string]any) (*PreviewResult, error) {
+	if !s.registry.Exists(input.TriggerCode) {
+		return nil, ErrTriggerNotFound.WithMsg("触发点不存在: " + input.TriggerCode)
+	}
+	language, err := s.normalizeLanguage(input.Language)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkLayout(ctx, input.Layout, language); err != nil {
+		return nil, err
+	}
+
+	// 未保存的模板 ID 为 0，按内容摘要缓存解析结果
+	return s.preview(ctx, &model.Template{
+		TriggerCode: input.TriggerCode,
+		Language:    language,
+		Subject:     input.Subject,
+		BodyHTML:    input.BodyHTML,
+		BodyText:    input.BodyText,
+		Layout:      input.Layout,
+	}, params)
+}
+
+// preview 渲染预览：示例值 < 通用参数 < 调用方参数
+func (s *Service) preview(ctx context.Context, template *model.Template, params map[string]any) (*PreviewResult, error) {
+	defs := s.registry.GetAllParams(template.TriggerCode)
+	values := exampleParams(defs)
+	for k, v := range s.mergeParams(params) {
+		values[k] = v
+	}
+
+	subject, err := s.engine.RenderWith(template.Subject, values, RenderOptions{Language: template.Language})
+	if err != nil {
+		return nil, err
+	}
+
+	// 与实际发送一致：套用布局与片段，未配置纯文本正文时展示自动转换结果
+	bodyHTML, bodyText, err := s.renderBody(ctx, template, values, TrustedParams(defs))
+	if err != nil {
+		return nil, err
+	}
+
+	return &PreviewResult{
+		Subject:  subject,
+		BodyHTML: bodyHTML,
+		BodyText: bodyText,
+	}, nil
+}
+
+// TestSend 测试发送
+func (s *Service) TestSend(ctx context.Context, id uint, recipient string) error {
+	if recipient == "" {
+		return ErrNoRecipient
+	}
+
+	template, err := s.templateRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// 测试发送使用最新修订内容（即模板当前字段）
+	template.PublishedVersion = template.LatestVersion
+
+	// 使用示例值渲染
+	params := exampleParams(s.registry.GetAllParams(template.TriggerCode))
+	params["CurrentYear"] = s.clock.Now().Year()
+
+	_, err = s.sendWithTemplate(ctx, template, recipient, params, nil, nil)
+	return err
+}
+
+// ========== 发送 ==========
+
+// Send 同步发送邮件（WithTx 绑定事务时改为入队，提交后投递）
+func (s *Service) Send(ctx context.Context, input SendInput) error {
+	if s.inTx {
+		return s.SendAsync(ctx, input)
+	}
+
+	recipients, err := s.validateSendInput(&input)
+	if err != nil {
+		return err
+	}
+	if replayed, err := s.replayIdempotent(ctx, &input, recipients, false); replayed || err != nil {
+		return err
+	}
+
+	template, err := s.resolveTemplate(ctx, input.TriggerCode, input.Language)
+	if err != nil {
+		return err
+	}
+
+	// 合并参数并按触发点定义校验
+	params, err := ValidateParams(s.registry.GetAllParams(input.TriggerCode), s.mergeParams(input.Params))
+	if err != nil {
+		return err
+	}
+
+	// 每个收件人单独发送并记录日志
+	var errs []error
+	for _, recipient := range recipients {
+		err := s.sendToRecipient(ctx, template, recipient, params, input)
+		if errors.Is(err, ErrIdempotencyKeyUsed) {
+			// 并发的重复请求先创建了日志：返回其结果
+			_, err = s.replayIdempotent(ctx, &input, recipients, false)
+			return err
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return joinSendErrors(errs, len(recipients))
+}
+
+// sendToRecipient 向单个收件人发送，临时错误交给发送队列按退避时间重试
+func (s *Service) sendToRecipient(ctx context.Context, template *model.Template, recipient string, params map[string]any, input SendInput) error {
+	sendLog, err := s.sendWithTemplate(ctx, template, recipient, params, &input, nil)
+	if err != nil && sendLog != nil && sendLog.Status == model.SendStatusRetrying {
+		if scheduleErr := s.scheduleRetry(ctx, sendLog, input); scheduleErr != nil {
+			return s.failSendLog(ctx, sendLog, scheduleErr)
+		}
+		return ErrSendFailed.WithMsg("邮件发送失败，已安排重试").Wrap(err)
+	}
+	return err
+}
+
+// joinSendErrors 合并多个收件人的发送错误
+func joinSendErrors(errs []error, total int) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		if total == 1 {
+			return errs[0]
+		}
+	}
+	return ErrSendFailed.WithMsg(fmt.Sprintf("%d/%d 个收件人发送失败", len(errs), total)).Wrap(errors.Join(errs...))
+}
+
+// SendAsync 异步发送邮件（持久化到发送队列，由 Worker 领取执行）
+func (s *Service) SendAsync(ctx context.Context, input SendInput) error {
+	recipients, err := s.validateSendInput(&input)
+	if err != nil {
+		return err
+	}
+	if replayed, err := s.replayIdempotent(ctx, &input, recipients, true); replayed || err != nil {
+		return err
+	}
+
+	template, err := s.resolveTemplate(ctx, input.TriggerCode, input.Language)
+	if err != nil {
+		return err
+	}
+
+	params, err := ValidateParams(s.registry.GetAllParams(input.TriggerCode), s.mergeParams(input.Params))
+	if err != nil {
+		return err
+	}
+
+	// 入队前渲染主题：提前暴露模板错误，同时让日志列表可读
+	subject, err := s.engine.RenderWith(subjectSource(template, &input), params, RenderOptions{Language: template.Language})
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(input)
+	if err != nil {
+		return ErrInvalidInput.Wrap(err)
+	}
+	paramsJSON, _ := json.Marshal(persistParams(params))
+
+	// 日志与任务在同一事务内写入，避免出现无任务的排队日志；每个收件人一条日志和任务
+	now := s.clock.Now()
+	err = s.transaction(ctx, func(tx *Service) error {
+		for _, recipient := range recipients {
+			sendLog := &model.SendLog{
+				TemplateID:        &template.ID,
+				TemplateVersion:   template.PublishedVersion,
+				TriggerCode:       template.TriggerCode,
+				Language:          template.Language,
+				RequestedLanguage: input.Language,
+				Recipient:         recipient,
+				Subject:           subject,
+				Params:            string(paramsJSON),
+				Status:            model.SendStatusQueued,
+				IdempotencyKey:    idempotencyKey(&input),
+				CreatedAt:         now,
+			}
+			if err := tx.logRepo.Create(ctx, sendLog); err != nil {
+				return err
+			}
+
+			job := &model.SendJob{
+				SendLogID:   sendLog.ID,
+				TriggerCode: template.TriggerCode,
+				Payload:     string(payload),
+				Status:      model.JobStatusQueued,
+				AvailableAt: now,
+			}
+			if err := tx.jobRepo.Create(ctx, job); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, ErrIdempotencyKeyUsed) {
+		// 并发的重复请求先入队：返回其结果
+		_, err = s.replayIdempotent(ctx, &input, recipients, true)
+		return err
+	}
+	if err != nil {
+		return ErrDatabaseError.Wrap(err)
+	}
+
+	return nil
+}
+
+// replayIdempotent 幂等键在有效期内已使用时返回原发送结果（replayed 为 true）
+// 同步发送返回原日志的发送结果（发送中视为成功），异步发送视为已入队；超出有效期的原日志解除幂等键，按新请求发送
+func (s *Service) replayIdempotent(ctx context.Context, input *SendInput, recipients []string, async bool) (bool, error) {
+	if input.IdempotencyKey == "" {
+		return false, nil
+	}
+	logs, err := s.logRepo.ListByIdempotencyKey(ctx, input.IdempotencyKey)
+	if err != nil || len(logs) == 0 {
+		return false, err
+	}
+
+	if s.idempotencyWindow > 0 && s.clock.Now().Sub(logs[0].CreatedAt) > s.idempotencyWindow {
+		for i := range logs {
+			logs[i].IdempotencyKey = nil
+			if err := s.logRepo.Update(ctx, &logs[i]); err != nil {
+				return false, ErrDatabaseError.Wrap(err)
+			}
+		}
+		return false, nil
+	}
+
+	if !sameSendRequest(logs, input.TriggerCode, recipients) {
+		return true, ErrIdempotencyKeyUsed.WithMsg("幂等键已用于其他触发点或收件人: " + input.IdempotencyKey)
+	}
+	if async {
+		return true, nil
+	}
+
+	var errs []error
+	for _, l := range logs {
+		if l.Status == model.SendStatusFailed {
+			errs = append(errs, ErrSendFailed.WithMsg(l.ErrorMessage))
+		}
+	}
+	return true, joinSendErrors(errs, len(logs))
+}
+
+// sameSendRequest 原日志是否与本次请求的触发点、收件人一致
+func sameSendRequest(logs []model.SendLog, triggerCode string, recipients []string) bool {
+	if len(logs) != len(recipients) {
+		return false
+	}
+	pending := make(map[string]bool, len(recipients))
+	for _, r := range recipients {
+		pending[r] = true
+	}
+	for _, l := range logs {
+		if l.TriggerCode != triggerCode || !pending[l.Recipient] {
+			return false
+		}
+		delete(pending, l.Recipient)
+	}
+	return true
+}
+
+// idempotencyKey 日志的幂等键（未设置时为 nil，不参与唯一约束）
+func idempotencyKey(input *SendInput) *string {
+	if input == nil || input.IdempotencyKey == "" {
+		return nil
+	}
+	key := input.IdempotencyKey
+	return &key
+}
+
+// validateSendInput 校验发送输入（规范化语言），返回解析后的收件人列表
+func (s *Service) validateSendInput(input *SendInput) ([]string, error) {
+	if input.TriggerCode == "" {
+		return nil, ErrInvalidInput.WithMsg("触发点代码不能为空")
+	}
+	if strings.TrimSpace(input.Recipient) == "" {
+		return nil, ErrNoRecipient
+	}
+
+	// 验证触发点存在
+	if !s.registry.Exists(input.TriggerCode) {
+		return nil, ErrTriggerNotFound.WithMsg("触发点不存在: " + input.TriggerCode)
+	}
+
+	language, err := NormalizeLanguage(input.Language)
+	if err != nil {
+		return nil, err
+	}
+	input.Language = language
+
+	input.IdempotencyKey = strings.TrimSpace(input.IdempotencyKey)
+	if len(input.IdempotencyKey) > maxIdempotencyKeyLength {
+		return nil, ErrInvalidInput.WithMsg(fmt.Sprintf("幂等键不能超过 %d 个字符", maxIdempotencyKeyLength))
+	}
+
+	return ParseRecipients(input.Recipient)
+}
+
+// maxIdempotencyKeyLength 幂等键最大长度（与 email_send_logs.idempotency_key 列宽一致）
+const maxIdempotencyKeyLength = 128
+
+// ParseRecipients 解析收件人列表（逗号分隔，支持 "Name <addr>" 格式）
+func ParseRecipients(recipient string) ([]string, error) {
+	addrs, err := mail.ParseAddressList(recipient)
+	if err != nil {
+		return nil, ErrInvalidInput.WithMsg("收件人格式无效: " + recipient).Wrap(err)
+	}
+	if len(addrs) == 0 {
+		return nil, ErrNoRecipient
+	}
+
+	recipients := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		if addr.Name == "" {
+			recipients = append(recipients, addr.Address)
+		} else {
+			recipients = append(recipients, addr.String())
+		}
+	}
+	return recipients, nil
+}
+
+// subjectSource 主题模板（SendInput.Subject 覆盖模板主题）
+func subjectSource(template *model.Template, input *SendInput) string {
+	if input != nil && input.Subject != "" {
+		return input.Subject
+	}
+	return template.Subject
+}
+
+// resolveTemplate 获取启用的模板（按语言回退链依次查找，如 pt-BR → pt → 回退语言 → 默认语言）
+func (s *Service) resolveTemplate(ctx context.Context, triggerCode, language string) (*model.Template, error) {
+	for _, lang := range s.languageChain(language) {
+		template, err := s.templateRepo.GetActiveTemplate(ctx, triggerCode, lang)
+		if errors.Is(err, ErrTemplateNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return s.publishedTemplate(ctx, template)
+	}
+	return nil, ErrTemplateNotFound
+}
+
+// languageChain 指定语言的回退链（为空时从默认语言开始）
+func (s *Service) languageChain(language string) []string {
+	if language == "" {
+		language = s.defaultLanguage
+	}
+	return LanguageFallbackChain(language, s.languageFallbacks, s.defaultLanguage)
+}
+
+// normalizeLanguage 规范化语言标签，为空时使用默认语言
+func (s *Service) normalizeLanguage(language string) (string, error) {
+	language, err := NormalizeLanguage(language)
+	if err != nil || language != "" {
+		return language, err
+	}
+	return s.defaultLanguage, nil
+}
+
+// NegotiateLanguage 按 Accept-Language 请求头从触发点已启用模板的语言中协商发送语言
+// 无匹配时返回默认语言；结果可直接作为 SendInput.Language
+func (s *Service) NegotiateLanguage(ctx context.Context, triggerCode, acceptLanguage string) (string, error) {
+	result, err := s.templateRepo.List(ctx, TemplateFilter{
+		TriggerCode: triggerCode,
+		Status:      model.TemplateStatusEnabled,
+		PageSize:    1000,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	available := make([]string, 0, len(result.Items))
+	for _, t := range result.Items {
+		available = append(available, t.Language)
+	}
+	sort.Strings(available)
+
+	if lang := NegotiateLanguage(acceptLanguage, available); lang != "" {
+		return lang, nil
+	}
+	return s.defaultLanguage, nil
+}
+
+// publishedTemplate 返回已发布版本内容的模板副本（无版本记录时直接使用模板内容）
+func (s *Service) publishedTemplate(ctx context.Context, template *model.Template) (*model.Template, error) {
+	if template.PublishedVersion == 0 || template.PublishedVersion == template.LatestVersion {
+		return template, nil
+	}
+	return s.templateAtVersion(ctx, template, template.PublishedVersion)
+}
+
+// templateAtVersion 返回指定版本内容的模板副本，副本的 PublishedVersion 即内容所属版本
+func (s *Service) templateAtVersion(ctx context.Context, template *model.Template, version int) (*model.Template, error) {
+	v, err := s.versionRepo.Get(ctx, template.ID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	clone := *template
+	v.ApplyTo(&clone)
+	clone.PublishedVersion = version
+	return &clone, nil
+}
+
+// scheduleRetry 为等待重试的日志创建队列任务
+func (s *Service) scheduleRetry(ctx context.Context, sendLog *model.SendLog, input SendInput) error {
+	payload, err := json.Marshal(input)
+	if err != nil {
+		return ErrInvalidInput.Wrap(err)
+	}
+
+	job := &model.SendJob{
+		SendLogID:   sendLog.ID,
+		TriggerCode: sendLog.TriggerCode,
+		Payload:     string(payload),
+		Status:      model.JobStatusQueued,
+		AvailableAt: *sendLog.NextAttemptAt,
+	}
+	if err := s.jobRepo.Create(ctx, job); err != nil {
+		return ErrDatabaseError.Wrap(err)
+	}
+	return nil
+}
+
+// processJob 执行队列任务：使用入队时的模板和参数发送，并更新关联日志
+// 返回的日志状态为 retrying 时，调用方应按 NextAttemptAt 重新入队
+func (s *Service) processJob(ctx context.Context, job *model.SendJob) (*model.SendLog, error) {
+	sendLog, err := s.logRepo.GetByID(ctx, job.SendLogID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 已发送（Worker 发送后、写回任务前崩溃，任务被重新领取）时不重复投递
+	if sendLog.Status == model.SendStatusSent {
+		return sendLog, nil
+	}
+
+	var input SendInput
+	if err := decodeJSON(job.Payload, &input); err != nil {
+		return sendLog, s.failSendLog(ctx, sendLog, ErrInvalidInput.Wrap(err))
+	}
+	return s.deliverLog(ctx, sendLog, input)
+}
+
+// abandonJob 放弃队列任务：发送日志标记为失败（已发送的日志保持不变）
+func (s *Service) abandonJob(ctx context.Context, job *model.SendJob, cause error) (*model.SendLog, error) {
+	sendLog, err := s.logRepo.GetByID(ctx, job.SendLogID)
+	if err != nil {
+		return nil, err
+	}
+	if sendLog.Status == model.SendStatusSent {
+		return sendLog, nil
+	}
+	return sendLog, s.failSendLog(ctx, sendLog, cause)
+}
+
+// deliverLog 按日志记录的模板版本与参数发送，并更新日志
+func (s *Service) deliverLog(ctx context.Context, sendLog *model.SendLog, input SendInput) (*model.SendLog, error) {
+	params := make(map[string]any)
+	if sendLog.Params != "" {
+		if err := decodeJSON(sendLog.Params, &params); err != nil {
+			return sendLog, s.failSendLog(ctx, sendLog, ErrInvalidInput.Wrap(err))
+		}
+	}
+	params = restoreParams(params)
+
+	if sendLog.TemplateID == nil {
+		return sendLog, s.failSendLog(ctx, sendLog, ErrTemplateNotFound)
+	}
+	template, err := s.templateRepo.GetByID(ctx, *sendLog.TemplateID)
+	if err != nil {
+		return sendLog, s.failSendLog(ctx, sendLog, err)
+	}
+
+	// 使用入队时记录的模板版本；记录的即最新修订时直接用当前字段，
+	// 同时将 PublishedVersion 对齐到最新修订，避免按旧发布版本号命中解析缓存
+	switch sendLog.TemplateVersion {
+	case 0:
+		// 版本化之前的日志未记录版本：按当前发布版本投递，不发送未发布的草稿
+		template, err = s.publishedTemplate(ctx, template)
+	case template.LatestVersion:
+		template.PublishedVersion = template.LatestVersion
+	default:
+		template, err = s.templateAtVersion(ctx, template, sendLog.TemplateVersion)
+	}
+	if err != nil {
+		return sendLog, s.failSendLog(ctx, sendLog, err)
+	}
+
+	if _, err := s.sendWithTemplate(ctx, template, sendLog.Recipient, params, &input, sendLog); err != nil {
+		// 渲染失败时 sendWithTemplate 不会更新日志
+		if sendLog.Status != model.SendStatusFailed && sendLog.Status != model.SendStatusRetrying {
+			return sendLog, s.failSendLog(ctx, sendLog, err)
+		}
+		return sendLog, err
+	}
+	return sendLog, nil
+}
+
+// renderBody 渲染 HTML 与纯文本正文（含布局与片段）
+// 未配置纯文本正文时由 HTML 自动转换；布局无纯文本版本时纯文本正文不套用布局
+func (s *Service) renderBody(ctx context.Context, template *model.Template, params map[string]any, trusted map[string]bool) (string, string, error) {
+	set, err := s.partialsFor(ctx, template.Language)
+	if err != nil {
+		return "", "", err
+	}
+	key := renderCacheKey(template, set)
+
+	bodyHTML, err := s.engine.RenderHTMLWith(template.BodyHTML, params, RenderOptions{Partials: set.HTML, Layout: template.Layout, CacheKey: key, Language: template.Language, Trusted: trusted})
+	if err != nil {
+		return "", "", err
+	}
+	if template.BodyText == "" {
+		return bodyHTML, HTMLToText(bodyHTML), nil
+	}
+
+	textOpts := RenderOptions{Partials: set.Text, CacheKey: key, Language: template.Language}
+	if _, ok := set.Text[template.Layout]; ok {
+		textOpts.Layout = template.Layout
+	}
+	bodyText, err := s.engine.RenderWith(template.BodyText, params, textOpts)
+	if err != nil {
+		return "", "", err
+	}
+	return bodyHTML, bodyText, nil
+}
+
+// renderCacheKey 正文解析缓存键：模板 ID + 内容版本号 + 片段摘要
+// 约定 template.PublishedVersion 为结构体当前内容对应的版本（版本内容不可变）；未保存或无版本记录的模板返回空，按内容摘要缓存
+func renderCacheKey(template *model.Template, set *partialSet) string {
+	if template.ID == 0 || template.PublishedVersion == 0 {
+		return ""
+	}
+	return fmt.Sprintf("tpl:%d:v%d:%s", template.ID, template.PublishedVersion, set.Digest)
+}
+
+// failSendLog 将日志标记为失败并返回原错误
+func (s *Service) failSendLog(ctx context.Context, sendLog *model.SendLog, cause error) error {
+	sendLog.MarkFailed(cause.Error(), s.clock.Now())
+	s.updateSendLog(ctx, sendLog)
+	return cause
+}
+
+// sendWithTemplate 使用模板发送邮件
+// sendLog 为 nil 时新建日志；异步任务传入入队时创建的日志
+// 发送失败且按重试策略可重试时，日志标记为 retrying，由调用方安排重试（测试发送 input 为 nil，不重试）
+func (s *Service) sendWithTemplate(ctx context.Context, template *model.Template, recipient string, params map[string]any, input *SendInput, sendLog *model.SendLog) (*model.SendLog, error) {
+	// 渲染主题（支持 SendInput.Subject 覆盖）
+	subject, err := s.engine.RenderWith(subjectSource(template, input), params, RenderOptions{Language: template.Language})
+	if err != nil {
+		return sendLog, err
+	}
+
+	// 渲染正文（HTML 自动转义，可信参数可用 safeHTML/safeURL；纯文本未配置时由 HTML 自动转换）
+	body, bodyText, err := s.renderBody(ctx, template, params, TrustedParams(s.registry.GetAllParams(template.TriggerCode)))
+	if err != nil {
+		return sendLog, err
+	}
+
+	// 创建发送日志
+	if sendLog == nil {
+		paramsJSON, _ := json.Marshal(persistParams(params))
+		sendLog = &model.SendLog{
+			TemplateID:      &template.ID,
+			TemplateVersion: template.PublishedVersion,
+			TriggerCode:     template.TriggerCode,
+			Language:        template.Language,
+			Recipient:       recipient,
+			Subject:         subject,
+			Params:          string(paramsJSON),
+			Status:          model.SendStatusPending,
+			IdempotencyKey:  idempotencyKey(input),
+			CreatedAt:       s.clock.Now(),
+		}
+		if input != nil {
+			sendLog.RequestedLanguage = input.Language
+		}
+		if err := s.logRepo.Create(ctx, sendLog); err != nil {
+			if errors.Is(err, ErrIdempotencyKeyUsed) {
+				return nil, err
+			}
+			return nil, ErrDatabaseError.Wrap(err)
+		}
+	} else {
+		sendLog.Subject = subject
+	}
+
+	// 构建邮件
+	msg := &Message{
+		To:       []string{recipient},
+		Subject:  subject,
+		HTMLBody: body,
+		TextBody: bodyText, // multipart/alternative 纯文本部分
+	}
+
+	// 发件人
+	if input != nil {
+		msg.From = input.From
+		msg.FromName = input.FromName
+	}
+
+	// 抄送：模板配置 + input 追加
+	msg.Cc = splitAddresses(template.Cc)
+	if input != nil {
+		msg.Cc = append(msg.Cc, input.Cc...)
+	}
+
+	// 密送：模板配置 + input 追加
+	msg.Bcc = splitAddresses(template.Bcc)
+	if input != nil {
+		msg.Bcc = append(msg.Bcc, input.Bcc...)
+	}
+
+	// 回复地址
+	if input != nil && input.ReplyTo != "" {
+		msg.ReplyTo = input.ReplyTo
+	} else {
+		msg.ReplyTo = template.ReplyTo
+	}
+
+	// 附件
+	if input != nil {
+		msg.Attachments = input.Attachments
+	}
+
+	// 发送
+	sendLog.Attempts++
+	messageID, sendErr := s.sender.Send(ctx, msg)
+
+	// 更新日志
+	if sendErr != nil {
+		policy := NoRetry
+		if input != nil {
+			policy = s.retryPolicyFor(template.TriggerCode)
+		}
+		now := s.clock.Now()
+		if policy.ShouldRetry(sendLog.Attempts, sendErr) {
+			sendLog.MarkRetrying(sendErr.Error(), now, now.Add(policy.Backoff(sendLog.Attempts)))
+		} else {
+			sendLog.MarkFailed(sendErr.Error(), now)
+		}
+	} else {
+		sendLog.MessageID = messageID
+		sendLog.MarkSent(s.clock.Now())
+	}
+	s.updateSendLog(ctx, sendLog)
+
+	if sendErr != nil {
+		return sendLog, ErrSendFailed.Wrap(sendErr)
+	}
+
+	return sendLog, nil
+}
+
+// updateSendLog 更新日志（失败仅记录，不影响发送结果）
+func (s *Service) updateSendLog(ctx context.Context, sendLog *model.SendLog) {
+	if err := s.logRepo.Update(ctx, sendLog); err != nil {
+		s.logger.ErrorContext(ctx, "update email send log failed", "log_id", sendLog.ID, "error", err)
+	}
+}
+
+// retryPolicyFor 获取触发点的重试策略（未单独配置时使用默认策略）
+func (s *Service) retryPolicyFor(triggerCode string) RetryPolicy {
+	if trigger, ok := s.registry.Get(triggerCode); ok && trigger.RetryPolicy != nil {
+		return *trigger.RetryPolicy
+	}
+	return s.retryPolicy
+}
+
+// mergeParams 合并参数
+func (s *Service) mergeParams(params map[string]any) map[string]any {
+	merged := make(map[string]any)
+
+	// 1. 注入通用参数（应用级）
+	for k, v := range s.commonParams {
+		merged[k] = v
 	}
 
 	// 2. 自动注入 CurrentYear（如未在 commonParams 中定义）
 	if _, ok := merged["CurrentYear"]; !ok {
-		merged["CurrentYear"] = time.Now().Year()
+		merged["CurrentYear"] = s.clock.Now().Year()
 	}
 
 	// 3. 用户传入的参数（优先级最高）
@@ -408,6 +1686,216 @@ func (s *Service) mergeParams(params map[string]any) map[string]any {
 	return merged
 }
 
+// ========== 重发 ==========
+
+// Resend 重发日志对应的邮件：还原日志记录的参数重新渲染，创建关联原日志（parent_log_id）的新日志
+// 默认使用原日志的模板版本，opts.UseCurrentVersion 时使用模板当前已发布版本（模板须已启用）
+// 仅还原模板与参数，原发送的抄送、附件等 SendInput 覆盖项不会重放；发送中（排队、重试中）的日志不可重发
+func (s *Service) Resend(ctx context.Context, logID uint, opts ResendOptions) (*model.SendLog, error) {
+	parent, err := s.logRepo.GetByID(ctx, logID)
+	if err != nil {
+		return nil, err
+	}
+	return s.resend(ctx, parent, opts, s.clock.Now())
+}
+
+// resend 重发指定日志，异步时任务在 availableAt 之后才会被 Worker 领取
+func (s *Service) resend(ctx context.Context, parent *model.SendLog, opts ResendOptions, availableAt time.Time) (*model.SendLog, error) {
+	switch parent.Status {
+	case model.SendStatusPending, model.SendStatusQueued, model.SendStatusRetrying:
+		return nil, ErrInvalidInput.WithMsg("日志发送中，不能重发")
+	}
+	if parent.TemplateID == nil {
+		return nil, ErrTemplateNotFound
+	}
+
+	template, err := s.templateRepo.GetByID(ctx, *parent.TemplateID)
+	if err != nil {
+		return nil, err
+	}
+	version := parent.TemplateVersion
+	if opts.UseCurrentVersion {
+		if template.Status != model.TemplateStatusEnabled {
+			return nil, ErrTemplateDisabled
+		}
+		version = template.PublishedVersion
+	}
+
+	recipient := parent.Recipient
+	if opts.Recipient != "" {
+		recipients, err := ParseRecipients(opts.Recipient)
+		if err != nil {
+			return nil, err
+		}
+		if len(recipients) != 1 {
+			return nil, ErrInvalidInput.WithMsg("重发只能指定一个收件人")
+		}
+		recipient = recipients[0]
+	}
+
+	sendLog := &model.SendLog{
+		ParentLogID:       &parent.ID,
+		TemplateID:        parent.TemplateID,
+		TemplateVersion:   version,
+		TriggerCode:       parent.TriggerCode,
+		Language:          parent.Language,
+		RequestedLanguage: parent.RequestedLanguage,
+		Recipient:         recipient,
+		Subject:           parent.Subject,
+		Params:            parent.Params,
+		Status:            model.SendStatusPending,
+		CreatedAt:         s.clock.Now(),
+	}
+	input := SendInput{TriggerCode: parent.TriggerCode, Recipient: recipient, Language: parent.RequestedLanguage}
+
+	// 异步：日志与任务在同一事务内写入，由 Worker 投递
+	if opts.Async {
+		sendLog.Status = model.SendStatusQueued
+		payload, err := json.Marshal(input)
+		if err != nil {
+			return nil, ErrInvalidInput.Wrap(err)
+		}
+		err = s.transaction(ctx, func(tx *Service) error {
+			if err := tx.logRepo.Create(ctx, sendLog); err != nil {
+				return err
+			}
+			return tx.jobRepo.Create(ctx, &model.SendJob{
+				SendLogID:   sendLog.ID,
+				TriggerCode: sendLog.TriggerCode,
+				Payload:     string(payload),
+				Status:      model.JobStatusQueued,
+				AvailableAt: availableAt,
+			})
+		})
+		if err != nil {
+			return nil, ErrDatabaseError.Wrap(err)
+		}
+		return sendLog, nil
+	}
+
+	if err := s.logRepo.Create(ctx, sendLog); err != nil {
+		return nil, ErrDatabaseError.Wrap(err)
+	}
+	if _, err := s.deliverLog(ctx, sendLog, input); err != nil {
+		if sendLog.Status == model.SendStatusRetrying {
+			if scheduleErr := s.scheduleRetry(ctx, sendLog, input); scheduleErr != nil {
+				return sendLog, s.failSendLog(ctx, sendLog, scheduleErr)
+			}
+			return sendLog, ErrSendFailed.WithMsg("邮件发送失败，已安排重试").Wrap(err)
+		}
+		return sendLog, err
+	}
+	return sendLog, nil
+}
+
+// resendPageSize 批量重发时查询日志的分页大小
+const resendPageSize = 200
+
+// ResendByFilter 批量重发符合条件的日志（如 SMTP 故障期间的失败日志）
+// 先取得全部匹配日志再逐条重发，新建的日志不会被本次重发再次匹配；单条失败不影响其他日志
+func (s *Service) ResendByFilter(ctx context.Context, filter LogFilter, opts ResendOptions) (*ResendResult, error) {
+	if opts.Recipient != "" {
+		return nil, ErrInvalidInput.WithMsg("批量重发不能指定收件人")
+	}
+
+	var ids []uint
+	filter.PageSize = resendPageSize
+	for page := 1; ; page++ {
+		filter.Page = page
+		result, err := s.logRepo.List(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range result.Items {
+			ids = append(ids, l.ID)
+		}
+		if page >= result.TotalPages {
+			break
+		}
+	}
+
+	result := &ResendResult{}
+	for _, id := range ids {
+		sendLog, err := s.Resend(ctx, id, opts)
+		switch {
+		case sendLog != nil:
+			// 已创建新日志（发送失败时新日志记录失败原因）
+			result.Resent = append(result.Resent, ResendItem{LogID: id, NewLogID: sendLog.ID, Status: sendLog.Status})
+		case err != nil:
+			result.Skipped = append(result.Skipped, ResendItem{LogID: id, Error: err.Error()})
+		}
+	}
+	return result, nil
+}
+
+// requeueBatchSize 重新入队时每批读取的失败日志数
+const requeueBatchSize = 200
+
+// RequeueFailed 将失败日志按 ID 顺序（游标）重新放入异步队列，用于服务商故障恢复后的批量补发
+// rateLimit 为每秒投递数：第 n 封的任务在 n/rateLimit 秒后才可领取，<= 0 时不限速
+// 同一收件人、触发点之后已有发送成功的日志，或该日志已被重发过时跳过（更新的失败日志不影响补发），
+// 重复执行不会重复补发；ctx 取消时停止并返回已处理部分的报告
+func (s *Service) RequeueFailed(ctx context.Context, filter LogFilter, rateLimit int) (*RequeueReport, error) {
+	filter.Status = model.SendStatusFailed
+
+	var interval time.Duration
+	if rateLimit > 0 {
+		interval = time.Second / time.Duration(rateLimit)
+	}
+	start := s.clock.Now()
+
+	report := &RequeueReport{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		batch, err := s.logRepo.ListAfterID(ctx, filter, report.Cursor, requeueBatchSize)
+		if err != nil {
+			return report, err
+		}
+		if len(batch) == 0 {
+			report.Done = true
+			return report, nil
+		}
+
+		for i := range batch {
+			parent := &batch[i]
+			report.Scanned++
+			report.Cursor = parent.ID
+
+			handled, err := s.logRepo.ExistsNewer(ctx, parent.TriggerCode, parent.Recipient, parent.ID)
+			if err != nil {
+				return report, err
+			}
+			if !handled {
+				if handled, err = s.logRepo.ExistsResend(ctx, parent.ID); err != nil {
+					return report, err
+				}
+			}
+			if handled {
+				report.Deduplicated++
+				continue
+			}
+
+			availableAt := start.Add(time.Duration(report.Requeued) * interval)
+			if _, err := s.resend(ctx, parent, ResendOptions{Async: true}, availableAt); err != nil {
+				report.Skipped = append(report.Skipped, ResendItem{LogID: parent.ID, Error: err.Error()})
+				continue
+			}
+			report.Requeued++
+			report.LastAvailableAt = &availableAt
+		}
+
+		s.logger.InfoContext(ctx, "requeue failed email logs",
+			"scanned", report.Scanned,
+			"requeued", report.Requeued,
+			"deduplicated", report.Deduplicated,
+			"skipped", len(report.Skipped),
+			"cursor", report.Cursor)
+	}
+}
+
 // ========== 日志查询 ==========
 
 // GetSendLogs 获取发送日志
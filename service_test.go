@@ -0,0 +1,1208 @@
+package email_notification
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KOMKZ/go-yogan-domain-email-notification/model"
+	"gorm.io/gorm"
+)
+
+func TestParseRecipients(t *testing.T) {
+	recipients, err := ParseRecipients(`a@example.com, "Zhang San" <zhang@example.com>,b@example.com`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"a@example.com", `"Zhang San" <zhang@example.com>`, "b@example.com"}
+	if len(recipients) != len(expected) {
+		t.Fatalf("expected %d recipients, got %d", len(expected), len(recipients))
+	}
+	for i := range expected {
+		if recipients[i] != expected[i] {
+			t.Errorf("expected '%s', got '%s'", expected[i], recipients[i])
+		}
+	}
+
+	if _, err := ParseRecipients("not-an-email"); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput, got %v", err)
+	}
+}
+
+// newTestService 使用内存仓储与 RecordingSender 构建服务
+func newTestService(t testing.TB, opts ...Option) (*Service, *RecordingSender) {
+	t.Helper()
+
+	registry := NewTriggerRegistry()
+	registry.SetCommonParams([]Param{{Name: "AppName", Type: ParamTypeString}})
+	registry.Register("user:registered", "用户注册", "", []Param{
+		{Name: "UserName", Type: ParamTypeString, Required: true},
+	})
+
+	sender := NewRecordingSender()
+	opts = append([]Option{WithMemoryRepositories(), WithSender(sender)}, opts...)
+	svc := NewService(nil, nil, registry, map[string]any{"AppName": "Yogan"}, opts...)
+
+	tpl, err := svc.CreateTemplate(context.Background(), CreateTemplateInput{
+		TriggerCode: "user:registered",
+		Name:        "注册欢迎",
+		Subject:     "欢迎加入 {{.AppName}}",
+		BodyHTML:    "<p>Hi {{.UserName}}</p>",
+	})
+	if err != nil {
+		t.Fatalf("create template: %v", err)
+	}
+	approveTemplate(t, svc, tpl.ID)
+	return svc, sender
+}
+
+// newGormTestService 使用 GORM（SQLite）仓储构建服务
+func newGormTestService(t testing.TB, db *gorm.DB, opts ...Option) (*Service, *RecordingSender) {
+	t.Helper()
+	opts = append([]Option{
+		WithTemplateRepository(NewGormTemplateRepository(db)),
+		WithTemplateVersionRepository(NewGormTemplateVersionRepository(db)),
+		WithTemplateAuditRepository(NewGormTemplateAuditRepository(db)),
+		WithPartialRepository(NewGormPartialRepository(db)),
+		WithSendLogRepository(NewGormSendLogRepository(db)),
+		WithSendJobRepository(NewGormSendJobRepository(db)),
+	}, opts...)
+	svc, sender := newTestService(t, opts...)
+	svc.db = db // 写操作在事务内执行
+	return svc, sender
+}
+
+// approveTemplate 以 alice 提交、bob 审核通过模板
+func approveTemplate(t testing.TB, svc *Service, id uint) {
+	t.Helper()
+	if _, err := svc.SubmitForReview(WithActor(context.Background(), "alice"), id); err != nil {
+		t.Fatalf("submit for review: %v", err)
+	}
+	if _, err := svc.Approve(WithActor(context.Background(), "bob"), id); err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+}
+
+func TestService_Send(t *testing.T) {
+	svc, sender := newTestService(t)
+	ctx := context.Background()
+
+	err := svc.Send(ctx, SendInput{
+		TriggerCode: "user:registered",
+		Recipient:   "a@example.com, Zhang <b@example.com>",
+		Params:      map[string]any{"UserName": "<b>张三</b>"},
+	})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	messages := sender.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	msg := messages[0]
+	if msg.Subject != "欢迎加入 Yogan" {
+		t.Errorf("unexpected subject: %s", msg.Subject)
+	}
+	if msg.HTMLBody != "<p>Hi &lt;b&gt;张三&lt;/b&gt;</p>" {
+		t.Errorf("expected escaped html body, got %s", msg.HTMLBody)
+	}
+	if msg.TextBody != "Hi <b>张三</b>" {
+		t.Errorf("expected text body converted from html, got %q", msg.TextBody)
+	}
+
+	logs, _ := svc.GetSendLogs(ctx, LogFilter{Status: model.SendStatusSent})
+	if logs.Total != 2 {
+		t.Errorf("expected 2 sent logs, got %d", logs.Total)
+	}
+
+	// 缺少必填参数
+	err = svc.Send(ctx, SendInput{TriggerCode: "user:registered", Recipient: "a@example.com"})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput, got %v", err)
+	}
+}
+
+func TestService_LanguageFallback(t *testing.T) {
+	svc, sender := newTestService(t, WithLanguageFallback("en"))
+	ctx := context.Background()
+
+	for _, lang := range []string{"en", "pt"} {
+		tpl, err := svc.CreateTemplate(ctx, CreateTemplateInput{
+			TriggerCode: "user:registered", Language: lang, Subject: "Welcome " + lang, BodyHTML: "<p>Hi</p>",
+		})
+		if err != nil {
+			t.Fatalf("create %s template: %v", lang, err)
+		}
+		approveTemplate(t, svc, tpl.ID)
+	}
+
+	tests := []struct {
+		language  string
+		requested string
+		resolved  string
+	}{
+		{"pt_br", "pt-BR", "pt"}, // 规范化后截断回退
+		{"en-AU", "en-AU", "en"},
+		{"ja", "ja", "en"}, // 配置的回退语言
+		{"", "", "zh-CN"},  // 未指定时使用默认语言
+	}
+	for _, tt := range tests {
+		if err := svc.Send(ctx, SendInput{TriggerCode: "user:registered", Language: tt.language, Recipient: "a@example.com", Params: map[string]any{"UserName": "Tom"}}); err != nil {
+			t.Fatalf("send %q: %v", tt.language, err)
+		}
+		logs, _ := svc.GetSendLogs(ctx, LogFilter{})
+		latest := logs.Items[0]
+		if latest.RequestedLanguage != tt.requested || latest.Language != tt.resolved {
+			t.Errorf("%q: expected requested %q resolved %q, got %q %q", tt.language, tt.requested, tt.resolved, latest.RequestedLanguage, latest.Language)
+		}
+	}
+	if got := len(sender.Messages()); got != len(tests) {
+		t.Errorf("expected %d messages, got %d", len(tests), got)
+	}
+
+	if err := svc.Send(ctx, SendInput{TriggerCode: "user:registered", Language: "en_US.UTF-8", Recipient: "a@example.com", Params: map[string]any{"UserName": "Tom"}}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for malformed language, got %v", err)
+	}
+
+	lang, err := svc.NegotiateLanguage(ctx, "user:registered", "pt-PT,pt;q=0.9,en;q=0.8")
+	if err != nil || lang != "pt" {
+		t.Errorf("expected pt, got %q (%v)", lang, err)
+	}
+	if lang, _ := svc.NegotiateLanguage(ctx, "user:registered", "fr-FR"); lang != DefaultLanguage {
+		t.Errorf("expected default language, got %q", lang)
+	}
+}
+
+func TestService_TranslationCoverage(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	svc, _ := newTestService(t, WithClock(ClockFunc(func() time.Time { return now })))
+	svc.registry.Register("order:paid", "订单支付", "", nil)
+	ctx := context.Background()
+
+	// en 晚于主语言创建，之后主语言修改内容 → en 过期
+	now = now.Add(time.Hour)
+	en, err := svc.CreateTemplate(ctx, CreateTemplateInput{TriggerCode: "user:registered", Language: "en", Subject: "Welcome", BodyHTML: "<p>Hi</p>"})
+	if err != nil {
+		t.Fatalf("create en template: %v", err)
+	}
+	report, err := svc.TranslationCoverage(ctx, []string{"en", "ja"})
+	if err != nil {
+		t.Fatalf("coverage: %v", err)
+	}
+	if got := report.Triggers[1].Languages[1]; got.Status != CoverageDraft || got.Stale {
+		t.Errorf("expected fresh draft en translation, got %+v", got)
+	}
+
+	now = now.Add(time.Hour)
+	primary, _ := svc.GetTemplateByTrigger(ctx, "user:registered", "zh-CN")
+	subject := "欢迎加入 {{.AppName}}！"
+	if _, err := svc.UpdateTemplate(ctx, primary.ID, UpdateTemplateInput{Subject: &subject}); err != nil {
+		t.Fatalf("update primary: %v", err)
+	}
+
+	report, err = svc.TranslationCoverage(ctx, []string{"ja", "EN"})
+	if err != nil {
+		t.Fatalf("coverage: %v", err)
+	}
+	if want := []string{"zh-CN", "ja", "en"}; strings.Join(report.Languages, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected languages %v, got %v", want, report.Languages)
+	}
+	if len(report.Triggers) != 2 || report.Triggers[0].TriggerCode != "order:paid" {
+		t.Fatalf("unexpected triggers: %+v", report.Triggers)
+	}
+	for _, entry := range report.Triggers[0].Languages {
+		if entry.Status != CoverageMissing {
+			t.Errorf("order:paid/%s: expected missing, got %s", entry.Language, entry.Status)
+		}
+	}
+
+	registered := report.Triggers[1].Languages
+	if registered[0].Status != CoverageEnabled || !registered[0].ContentUpdatedAt.Equal(now) {
+		t.Errorf("unexpected primary coverage: %+v", registered[0])
+	}
+	if registered[1].Status != CoverageMissing {
+		t.Errorf("expected ja missing, got %s", registered[1].Status)
+	}
+	if registered[2].TemplateID != en.ID || registered[2].Status != CoverageDraft || !registered[2].Stale {
+		t.Errorf("expected stale en draft, got %+v", registered[2])
+	}
+
+	// 未指定语言时使用已有模板的全部语言
+	report, _ = svc.TranslationCoverage(ctx, nil)
+	if strings.Join(report.Languages, ",") != "zh-CN,en" {
+		t.Errorf("unexpected default languages: %v", report.Languages)
+	}
+}
+
+func TestService_LintTemplate(t *testing.T) {
+	svc, _ := newTestService(t)
+	svc.registry.Register("order:paid", "订单支付", "", []Param{
+		{Name: "OrderNo", Type: ParamTypeString, Required: true},
+		{Name: "Amount", Type: ParamTypeNumber, Required: true},
+		{Name: "Items", Type: ParamTypeArray},
+		{Name: "Banner", Type: ParamTypeString, Trusted: true},
+	})
+	ctx := context.Background()
+
+	result, err := svc.LintTemplate(ctx, CreateTemplateInput{
+		TriggerCode: "order:paid",
+		Subject:     "订单 {{.OrderNO}} 已支付",
+		BodyHTML:    "<p>{{safeHTML .Banner}}</p>\n{{range .Items}}<li>{{.Name}} {{$.AppName}}</li>{{end}}\n<p>{{.OrderNo | safeHTML}}</p>",
+		BodyText:    "{{.OrderNo}}\n{{if .Amount}}",
+	})
+	if err != nil {
+		t.Fatalf("lint: %v", err)
+	}
+
+	expected := []LintDiagnostic{
+		{Field: "subject", Line: 1, Column: 6, Severity: LintSeverityError, Code: LintUndeclaredParam, Param: "OrderNO", Message: "未声明的参数: OrderNO（是否为 OrderNo？）"},
+		{Field: "body_html", Line: 3, Column: 17, Severity: LintSeverityError, Code: LintUnsafeConstruct, Param: "OrderNo", Message: "safeHTML 用于非可信参数: OrderNo"},
+		{Field: "body_text", Line: 2, Severity: LintSeverityError, Code: LintSyntaxError, Message: "unexpected EOF"},
+		{Severity: LintSeverityWarning, Code: LintUnusedRequiredParam, Param: "Amount", Message: "必填参数未使用: Amount"},
+	}
+	if len(result.Diagnostics) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %+v", len(expected), result.Diagnostics)
+	}
+	for i, want := range expected {
+		if got := result.Diagnostics[i]; got != want {
+			t.Errorf("diagnostic %d: expected %+v, got %+v", i, want, got)
+		}
+	}
+
+	// HTML 上下文不明确
+	result, _ = svc.LintTemplate(ctx, CreateTemplateInput{
+		TriggerCode: "order:paid",
+		Subject:     "{{.OrderNo}} {{.Amount}}",
+		BodyHTML:    `<a href="{{if .Banner}}/orders/{{else}}/search?q={{end}}{{.OrderNo}}">查看</a>{{template "missing" .}}`,
+	})
+	if len(result.Diagnostics) != 1 || result.Diagnostics[0].Code != LintUnknownPartial {
+		t.Errorf("expected unknown partial diagnostic, got %+v", result.Diagnostics)
+	}
+	result, _ = svc.LintTemplate(ctx, CreateTemplateInput{
+		TriggerCode: "order:paid",
+		Subject:     "{{.OrderNo}} {{.Amount}}",
+		BodyHTML:    `<a href="{{if .Banner}}/orders/{{else}}/search?q={{end}}{{.OrderNo}}">查看</a>`,
+	})
+	if len(result.Diagnostics) != 1 || result.Diagnostics[0].Code != LintUnsafeConstruct {
+		t.Errorf("expected unsafe construct diagnostic, got %+v", result.Diagnostics)
+	}
+
+	// 片段按调用时的 dot 统计参数使用：以字段调用时片段内的 .OrderNo 不是参数 OrderNo
+	if _, err := svc.CreatePartial(ctx, CreatePartialInput{Name: "order_no", BodyHTML: "<b>{{.OrderNo}}</b>"}); err != nil {
+		t.Fatalf("create partial: %v", err)
+	}
+	result, _ = svc.LintTemplate(ctx, CreateTemplateInput{
+		TriggerCode: "order:paid",
+		Subject:     "{{.Amount}}",
+		BodyHTML:    `{{template "order_no" .}}`,
+	})
+	if len(result.Diagnostics) != 0 {
+		t.Errorf("expected no diagnostics, got %+v", result.Diagnostics)
+	}
+	result, _ = svc.LintTemplate(ctx, CreateTemplateInput{
+		TriggerCode: "order:paid",
+		Subject:     "{{.Amount}}",
+		BodyHTML:    `{{range .Items}}{{template "order_no" .}}{{end}}`,
+	})
+	if len(result.Diagnostics) != 1 || result.Diagnostics[0].Code != LintUnusedRequiredParam || result.Diagnostics[0].Param != "OrderNo" {
+		t.Errorf("expected unused required param diagnostic, got %+v", result.Diagnostics)
+	}
+
+	// 保存时存在错误级别诊断则拒绝，警告不影响保存
+	_, err = svc.CreateTemplate(ctx, CreateTemplateInput{TriggerCode: "order:paid", Subject: "{{.OrderNO}}", BodyHTML: "<p>{{.Amount}}</p>"})
+	var lintErr *TemplateLintError
+	if !errors.Is(err, ErrTemplateLintFailed) || !errors.As(err, &lintErr) || lintErr.Diagnostics[0].Param != "OrderNO" {
+		t.Fatalf("expected ErrTemplateLintFailed, got %v", err)
+	}
+	tpl, err := svc.CreateTemplate(ctx, CreateTemplateInput{TriggerCode: "order:paid", Subject: "{{.OrderNo}}", BodyHTML: "<p>{{formatMoney \"CNY\" .Amount}}</p>"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	bad := "<p>{{.Amout}}</p>"
+	if _, err := svc.UpdateTemplate(ctx, tpl.ID, UpdateTemplateInput{BodyHTML: &bad}); !errors.Is(err, ErrTemplateLintFailed) {
+		t.Errorf("expected ErrTemplateLintFailed on update, got %v", err)
+	}
+}
+
+func TestService_Preview(t *testing.T) {
+	fixed := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	svc, sender := newTestService(t, WithClock(ClockFunc(func() time.Time { return fixed })))
+	ctx := context.Background()
+	tpl, _ := svc.GetTemplateByTrigger(ctx, "user:registered", "zh-CN")
+
+	// 无示例值的参数保留占位符，通用参数按发送时合并
+	preview, err := svc.PreviewTemplate(ctx, tpl.ID)
+	if err != nil {
+		t.Fatalf("preview: %v", err)
+	}
+	if preview.Subject != "欢迎加入 Yogan" || preview.BodyHTML != "<p>Hi {{.UserName}}</p>" {
+		t.Errorf("unexpected preview: %+v", preview)
+	}
+
+	preview, err = svc.PreviewTemplateWithParams(ctx, tpl.ID, map[string]any{"UserName": "<b>Tom</b>", "AppName": "Demo"})
+	if err != nil {
+		t.Fatalf("preview with params: %v", err)
+	}
+	if preview.Subject != "欢迎加入 Demo" || preview.BodyHTML != "<p>Hi &lt;b&gt;Tom&lt;/b&gt;</p>" || preview.BodyText != "Hi <b>Tom</b>" {
+		t.Errorf("unexpected preview: %+v", preview)
+	}
+
+	// 未保存的草稿按语言渲染
+	draft := CreateTemplateInput{
+		TriggerCode: "user:registered",
+		Language:    "de_DE",
+		Subject:     "Willkommen {{.UserName}}",
+		BodyHTML:    "<p>{{formatNumber 1234.5}} · © {{.CurrentYear}}</p>",
+	}
+	preview, err = svc.PreviewDraft(ctx, draft, map[string]any{"UserName": "Anna"})
+	if err != nil {
+		t.Fatalf("preview draft: %v", err)
+	}
+	if preview.Subject != "Willkommen Anna" || preview.BodyHTML != "<p>1.234,5 · © 2030</p>" {
+		t.Errorf("unexpected draft preview: %+v", preview)
+	}
+
+	draft.Layout = "missing"
+	if _, err := svc.PreviewDraft(ctx, draft, nil); !errors.Is(err, ErrPartialNotFound) {
+		t.Errorf("expected ErrPartialNotFound, got %v", err)
+	}
+	if _, err := svc.PreviewDraft(ctx, CreateTemplateInput{TriggerCode: "unknown"}, nil); !errors.Is(err, ErrTriggerNotFound) {
+		t.Errorf("expected ErrTriggerNotFound, got %v", err)
+	}
+	if len(sender.Messages()) != 0 {
+		t.Error("preview must not send")
+	}
+}
+
+func TestService_Resend(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local)
+	svc, sender := newTestService(t, WithRetryPolicy(NoRetry), WithClock(ClockFunc(func() time.Time { return now })))
+	ctx := WithActor(context.Background(), "alice")
+	tpl, _ := svc.GetTemplateByTrigger(ctx, "user:registered", "zh-CN")
+
+	// SMTP 故障期间发送失败
+	sender.FailWith(func(msg *Message) error { return errors.New("554 transaction failed") })
+	if err := svc.Send(ctx, SendInput{TriggerCode: "user:registered", Recipient: "a@example.com, b@example.com", Params: map[string]any{"UserName": "Tom"}}); err == nil {
+		t.Fatal("expected send failure")
+	}
+	sender.FailWith(nil)
+	failed, _ := svc.GetSendLogs(ctx, LogFilter{Status: model.SendStatusFailed})
+	if failed.Total != 2 {
+		t.Fatalf("expected 2 failed logs, got %d", failed.Total)
+	}
+	original := failed.Items[0]
+	if errs := original.Errors(); len(errs) != 1 || !errs[0].At.Equal(now) {
+		t.Errorf("expected failure recorded at service clock %v, got %+v", now, errs)
+	}
+
+	// 发布新版本：停用 → 草稿 → 修改并发布 → 审核通过
+	for _, status := range []model.TemplateStatus{model.TemplateStatusDisabled, model.TemplateStatusDraft} {
+		if _, err := svc.UpdateTemplate(ctx, tpl.ID, UpdateTemplateInput{Status: &status}); err != nil {
+			t.Fatalf("update status %s: %v", status, err)
+		}
+	}
+	subject := "Hello {{.UserName}}"
+	if _, err := svc.UpdateTemplate(ctx, tpl.ID, UpdateTemplateInput{Subject: &subject, Publish: true}); err != nil {
+		t.Fatalf("update subject: %v", err)
+	}
+	approveTemplate(t, svc, tpl.ID)
+
+	// 默认使用原版本重新渲染
+	now = now.Add(time.Hour)
+	resent, err := svc.Resend(ctx, original.ID, ResendOptions{})
+	if err != nil {
+		t.Fatalf("resend: %v", err)
+	}
+	if !resent.CreatedAt.Equal(now) || resent.SentAt == nil || !resent.SentAt.Equal(now) {
+		t.Errorf("expected resent log created and sent at %v, got %v / %v", now, resent.CreatedAt, resent.SentAt)
+	}
+	if resent.Status != model.SendStatusSent || resent.ParentLogID == nil || *resent.ParentLogID != original.ID {
+		t.Errorf("unexpected resent log: %+v", resent)
+	}
+	if resent.TemplateVersion != original.TemplateVersion || sender.Last().Subject != "欢迎加入 Yogan" || sender.Last().To[0] != original.Recipient {
+		t.Errorf("expected original version, got v%d %q", resent.TemplateVersion, sender.Last().Subject)
+	}
+
+	// 使用当前已发布版本并更换收件人
+	resent, err = svc.Resend(ctx, original.ID, ResendOptions{UseCurrentVersion: true, Recipient: "new@example.com"})
+	if err != nil {
+		t.Fatalf("resend current version: %v", err)
+	}
+	if sender.Last().Subject != "Hello Tom" || sender.Last().To[0] != "new@example.com" || resent.TemplateVersion <= original.TemplateVersion {
+		t.Errorf("expected current version to new recipient, got %q to %v", sender.Last().Subject, sender.Last().To)
+	}
+
+	// 发送中的日志不可重发
+	if err := svc.SendAsync(ctx, SendInput{TriggerCode: "user:registered", Recipient: "c@example.com", Params: map[string]any{"UserName": "Tom"}}); err != nil {
+		t.Fatalf("send async: %v", err)
+	}
+	queued, _ := svc.GetSendLogs(ctx, LogFilter{Status: model.SendStatusQueued})
+	if _, err := svc.Resend(ctx, queued.Items[0].ID, ResendOptions{}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for queued log, got %v", err)
+	}
+
+	// 按条件批量入队重发
+	result, err := svc.ResendByFilter(ctx, LogFilter{Status: model.SendStatusFailed}, ResendOptions{Async: true})
+	if err != nil {
+		t.Fatalf("resend by filter: %v", err)
+	}
+	if len(result.Resent) != 2 || len(result.Skipped) != 0 {
+		t.Fatalf("unexpected bulk result: %+v", result)
+	}
+	for _, item := range result.Resent {
+		if item.Status != model.SendStatusQueued {
+			t.Errorf("expected queued resend, got %+v", item)
+		}
+	}
+	queued, _ = svc.GetSendLogs(ctx, LogFilter{Status: model.SendStatusQueued})
+	if queued.Total != 3 {
+		t.Errorf("expected 3 queued logs, got %d", queued.Total)
+	}
+}
+
+func TestService_RequeueFailed(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local)
+	svc, sender := newTestService(t, WithRetryPolicy(NoRetry), WithClock(ClockFunc(func() time.Time { return now })))
+	ctx := context.Background()
+	send := func(recipient string) {
+		svc.Send(ctx, SendInput{TriggerCode: "user:registered", Recipient: recipient, Params: map[string]any{"UserName": "Tom"}})
+	}
+
+	// 故障期间：a、b、c 发送失败，之后 c 发送成功、a 再次失败
+	sender.FailWith(func(msg *Message) error { return errors.New("554 transaction failed") })
+	send("a@example.com, b@example.com")
+	send("c@example.com")
+	sender.FailWith(nil)
+	send("c@example.com")
+	sender.FailWith(func(msg *Message) error { return errors.New("554 transaction failed") })
+	send("a@example.com")
+	sender.FailWith(nil)
+	sender.Reset()
+
+	// c 之后已发送成功被跳过；a 的两封失败邮件均补发（更新的失败日志不影响补发）
+	report, err := svc.RequeueFailed(ctx, LogFilter{TriggerCode: "user:registered"}, 2)
+	if err != nil {
+		t.Fatalf("requeue failed: %v", err)
+	}
+	if report.Scanned != 4 || report.Requeued != 3 || report.Deduplicated != 1 || len(report.Skipped) != 0 || !report.Done {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.LastAvailableAt == nil || !report.LastAvailableAt.Equal(now.Add(time.Second)) {
+		t.Errorf("expected third job throttled by 1s, got %v", report.LastAvailableAt)
+	}
+
+	// 投递前重复执行：已重发的日志不再入队
+	report, err = svc.RequeueFailed(ctx, LogFilter{}, 0)
+	if err != nil || report.Requeued != 0 || report.Deduplicated != 4 {
+		t.Errorf("expected all deduplicated before delivery, got %+v (%v)", report, err)
+	}
+
+	// 限速：仅第一封已到期
+	worker := NewWorker(svc, WorkerOptions{BatchSize: 10})
+	if n, err := worker.RunOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("expected 1 due job, got %d (%v)", n, err)
+	}
+	now = now.Add(time.Second)
+	if n, err := worker.RunOnce(ctx); err != nil || n != 2 {
+		t.Fatalf("expected 2 more jobs, got %d (%v)", n, err)
+	}
+	var recipients []string
+	for _, msg := range sender.Messages() {
+		recipients = append(recipients, msg.To[0])
+	}
+	sort.Strings(recipients)
+	if strings.Join(recipients, ",") != "a@example.com,a@example.com,b@example.com" {
+		t.Errorf("expected both a and b requeued, got %v", recipients)
+	}
+
+	// 重复执行不会重复补发
+	report, err = svc.RequeueFailed(ctx, LogFilter{}, 0)
+	if err != nil || report.Requeued != 0 || report.Deduplicated != 4 {
+		t.Errorf("expected all deduplicated on rerun, got %+v (%v)", report, err)
+	}
+}
+
+func TestService_IdempotencyKey(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local)
+	svc, sender := newTestService(t, WithRetryPolicy(NoRetry), WithIdempotencyWindow(time.Hour),
+		WithClock(ClockFunc(func() time.Time { return now })))
+	ctx := context.Background()
+	input := SendInput{TriggerCode: "user:registered", Recipient: "a@example.com, b@example.com", Params: map[string]any{"UserName": "Tom"}, IdempotencyKey: "welcome-1"}
+
+	// 有效期内重复发送：返回原结果，不再发送
+	if err := svc.Send(ctx, input); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if err := svc.Send(ctx, input); err != nil {
+		t.Fatalf("repeat send: %v", err)
+	}
+	if err := svc.SendAsync(ctx, input); err != nil {
+		t.Fatalf("repeat send async: %v", err)
+	}
+	if n := len(sender.Messages()); n != 2 {
+		t.Fatalf("expected 2 messages, got %d", n)
+	}
+	logs, _ := svc.GetSendLogs(ctx, LogFilter{})
+	if logs.Total != 2 || logs.Items[0].IdempotencyKey == nil || *logs.Items[0].IdempotencyKey != "welcome-1" {
+		t.Fatalf("expected 2 logs with idempotency key, got %+v", logs.Items)
+	}
+
+	// 相同幂等键用于不同收件人
+	other := input
+	other.Recipient = "c@example.com"
+	if err := svc.Send(ctx, other); !errors.Is(err, ErrIdempotencyKeyUsed) {
+		t.Errorf("expected ErrIdempotencyKeyUsed, got %v", err)
+	}
+
+	// 原发送失败时返回原错误
+	sender.FailWith(func(msg *Message) error { return errors.New("554 transaction failed") })
+	failed := SendInput{TriggerCode: "user:registered", Recipient: "d@example.com", Params: map[string]any{"UserName": "Tom"}, IdempotencyKey: "welcome-2"}
+	if err := svc.Send(ctx, failed); err == nil {
+		t.Fatal("expected send failure")
+	}
+	sender.FailWith(nil)
+	if err := svc.Send(ctx, failed); !errors.Is(err, ErrSendFailed) {
+		t.Errorf("expected original failure, got %v", err)
+	}
+
+	// 超出有效期后按新请求发送
+	sender.Reset()
+	now = now.Add(2 * time.Hour)
+	if err := svc.SendAsync(ctx, input); err != nil {
+		t.Fatalf("send async after window: %v", err)
+	}
+	if n, err := NewWorker(svc, WorkerOptions{BatchSize: 10}).RunOnce(ctx); err != nil || n != 2 {
+		t.Fatalf("expected 2 jobs processed, got %d (%v)", n, err)
+	}
+	if n := len(sender.Messages()); n != 2 {
+		t.Errorf("expected 2 messages after window, got %d", n)
+	}
+
+	long := input
+	long.IdempotencyKey = strings.Repeat("k", 129)
+	if err := svc.Send(ctx, long); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for long key, got %v", err)
+	}
+}
+
+func TestService_SendTrustedParams(t *testing.T) {
+	svc, sender := newTestService(t)
+	svc.registry.Register("promo:sale", "促销", "", []Param{
+		{Name: "Link", Type: ParamTypeURL, Trusted: true},
+		{Name: "Tags", Type: ParamTypeArray, Trusted: true},
+	})
+	ctx := WithActor(context.Background(), "alice")
+	tpl, err := svc.CreateTemplate(ctx, CreateTemplateInput{
+		TriggerCode: "promo:sale",
+		Name:        "促销",
+		Subject:     "促销",
+		BodyHTML:    `{{if .Link}}<a href="{{safeURL .Link}}">go</a>{{else}}none{{end}}{{range .Tags}}<i>{{.}}</i>{{end}}`,
+	})
+	if err != nil {
+		t.Fatalf("create template: %v", err)
+	}
+	approveTemplate(t, svc, tpl.ID)
+
+	// 可信参数保持原值：空字符串为假，数组可 range
+	send := func(params map[string]any) string {
+		t.Helper()
+		if err := svc.Send(ctx, SendInput{TriggerCode: "promo:sale", Recipient: "a@example.com", Params: params}); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+		return sender.Last().HTMLBody
+	}
+	if got := send(map[string]any{"Link": "https://example.com/sale", "Tags": []string{"a", "b"}}); got != `<a href="https://example.com/sale">go</a><i>a</i><i>b</i>` {
+		t.Errorf("unexpected body: %s", got)
+	}
+	if got := send(map[string]any{"Link": ""}); got != "none" {
+		t.Errorf("expected empty trusted link to be falsy, got %s", got)
+	}
+}
+
+func TestService_SendAsync(t *testing.T) {
+	svc, sender := newTestService(t)
+	ctx := context.Background()
+
+	err := svc.SendAsync(ctx, SendInput{
+		TriggerCode: "user:registered",
+		Recipient:   "a@example.com",
+		Subject:     "Hi {{.UserName}}",
+		Params:      map[string]any{"UserName": "张三"},
+	})
+	if err != nil {
+		t.Fatalf("send async: %v", err)
+	}
+	if len(sender.Messages()) != 0 {
+		t.Fatal("expected no message before worker runs")
+	}
+
+	n, err := NewWorker(svc, WorkerOptions{}).RunOnce(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 job processed, got %d (%v)", n, err)
+	}
+	if msg := sender.Last(); msg == nil || msg.Subject != "Hi 张三" {
+		t.Errorf("expected subject override to be rendered, got %+v", msg)
+	}
+
+	logs, _ := svc.GetSendLogs(ctx, LogFilter{})
+	if logs.Total != 1 || logs.Items[0].Status != model.SendStatusSent {
+		t.Errorf("expected queued log to be marked sent, got %+v", logs.Items)
+	}
+}
+
+func TestService_TemplateVersions(t *testing.T) {
+	svc, sender := newTestService(t)
+	ctx := WithActor(context.Background(), "alice")
+
+	tpl, err := svc.GetTemplateByTrigger(ctx, "user:registered", "zh-CN")
+	if err != nil {
+		t.Fatalf("get template: %v", err)
+	}
+	if tpl.LatestVersion != 1 || tpl.PublishedVersion != 1 {
+		t.Fatalf("expected version 1 published on create, got latest=%d published=%d", tpl.LatestVersion, tpl.PublishedVersion)
+	}
+
+	send := func() *Message {
+		t.Helper()
+		err := svc.Send(ctx, SendInput{TriggerCode: "user:registered", Recipient: "a@example.com", Params: map[string]any{"UserName": "张三"}})
+		if err != nil {
+			t.Fatalf("send: %v", err)
+		}
+		return sender.Last()
+	}
+
+	// 未发布的修订不影响线上发送
+	subject := "新主题 {{.UserName}}"
+	tpl, err = svc.UpdateTemplate(ctx, tpl.ID, UpdateTemplateInput{Subject: &subject, ChangeNote: "改主题"})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if tpl.LatestVersion != 2 || tpl.PublishedVersion != 1 {
+		t.Fatalf("expected latest=2 published=1, got latest=%d published=%d", tpl.LatestVersion, tpl.PublishedVersion)
+	}
+	if msg := send(); msg.Subject != "欢迎加入 Yogan" {
+		t.Errorf("expected published subject, got %s", msg.Subject)
+	}
+
+	logs, _ := svc.GetSendLogs(ctx, LogFilter{})
+	if logs.Items[0].TemplateVersion != 1 {
+		t.Errorf("expected log to record version 1, got %d", logs.Items[0].TemplateVersion)
+	}
+
+	// 已上线模板不能绕过审核直接发布
+	if _, err := svc.PublishVersion(ctx, tpl.ID, 2); !errors.Is(err, ErrTemplateReviewRequired) {
+		t.Fatalf("expected ErrTemplateReviewRequired, got %v", err)
+	}
+
+	// 停用并退回草稿后发布版本 2，再经审核上线
+	toDraft := func() {
+		t.Helper()
+		for _, status := range []model.TemplateStatus{model.TemplateStatusDisabled, model.TemplateStatusDraft} {
+			if _, err := svc.UpdateTemplate(ctx, tpl.ID, UpdateTemplateInput{Status: &status}); err != nil {
+				t.Fatalf("set status %s: %v", status, err)
+			}
+		}
+	}
+	toDraft()
+	if _, err := svc.PublishVersion(ctx, tpl.ID, 2); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	approveTemplate(t, svc, tpl.ID)
+	if msg := send(); msg.Subject != "新主题 张三" {
+		t.Errorf("expected new subject after publish, got %s", msg.Subject)
+	}
+
+	// 回滚到版本 1
+	toDraft()
+	tpl, err = svc.RollbackTemplate(ctx, tpl.ID, 1, "")
+	if err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if tpl.LatestVersion != 3 || tpl.PublishedVersion != 3 || tpl.Subject != "欢迎加入 {{.AppName}}" {
+		t.Errorf("unexpected template after rollback: %+v", tpl)
+	}
+	approveTemplate(t, svc, tpl.ID)
+	if msg := send(); msg.Subject != "欢迎加入 Yogan" {
+		t.Errorf("expected rolled back subject, got %s", msg.Subject)
+	}
+
+	versions, _ := svc.ListTemplateVersions(ctx, tpl.ID)
+	if len(versions) != 3 || versions[1].Author != "alice" || versions[1].ChangeNote != "改主题" {
+		t.Errorf("unexpected versions: %+v", versions)
+	}
+}
+
+func TestService_ApproveKeepsPublishedVersion(t *testing.T) {
+	svc, sender := newTestService(t)
+	ctx := WithActor(context.Background(), "alice")
+	tpl, _ := svc.GetTemplateByTrigger(ctx, "user:registered", "zh-CN")
+
+	toDraft := func() {
+		t.Helper()
+		for _, status := range []model.TemplateStatus{model.TemplateStatusDisabled, model.TemplateStatusDraft} {
+			if _, err := svc.UpdateTemplate(ctx, tpl.ID, UpdateTemplateInput{Status: &status}); err != nil {
+				t.Fatalf("set status %s: %v", status, err)
+			}
+		}
+	}
+	send := func() string {
+		t.Helper()
+		if err := svc.Send(ctx, SendInput{TriggerCode: "user:registered", Recipient: "a@example.com", Params: map[string]any{"UserName": "张三"}}); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+		return sender.Last().Subject
+	}
+
+	// 发布版本 2 上线
+	toDraft()
+	subject := "V2 {{.UserName}}"
+	if _, err := svc.UpdateTemplate(ctx, tpl.ID, UpdateTemplateInput{Subject: &subject, Publish: true}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	approveTemplate(t, svc, tpl.ID)
+	if got := send(); got != "V2 张三" {
+		t.Fatalf("expected v2 subject, got %s", got)
+	}
+
+	// 发布旧版本 1，审核通过后上线的是版本 1 而不是最新修订
+	toDraft()
+	if _, err := svc.PublishVersion(ctx, tpl.ID, 1); err != nil {
+		t.Fatalf("publish version 1: %v", err)
+	}
+	approveTemplate(t, svc, tpl.ID)
+	tpl, _ = svc.GetTemplate(ctx, tpl.ID)
+	if tpl.PublishedVersion != 1 || tpl.LatestVersion != 2 {
+		t.Errorf("expected published=1 latest=2, got published=%d latest=%d", tpl.PublishedVersion, tpl.LatestVersion)
+	}
+	if got := send(); got != "欢迎加入 Yogan" {
+		t.Errorf("expected v1 subject after approve, got %s", got)
+	}
+}
+
+func TestService_ReviewWorkflow(t *testing.T) {
+	svc, _ := newTestService(t)
+	author := WithActor(context.Background(), "alice")
+	reviewer := WithActor(context.Background(), "bob")
+
+	if _, err := svc.CreateTemplate(author, CreateTemplateInput{
+		TriggerCode: "user:registered", Language: "en-US", Subject: "Welcome", BodyHTML: "<p>Hi</p>",
+		Status: model.TemplateStatusEnabled,
+	}); !errors.Is(err, ErrTemplateReviewRequired) {
+		t.Fatalf("expected ErrTemplateReviewRequired on create enabled, got %v", err)
+	}
+
+	tpl, err := svc.CreateTemplate(author, CreateTemplateInput{
+		TriggerCode: "user:registered", Language: "en-US", Subject: "Welcome", BodyHTML: "<p>Hi</p>",
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	enabled := model.TemplateStatusEnabled
+	if _, err := svc.UpdateTemplate(author, tpl.ID, UpdateTemplateInput{Status: &enabled}); !errors.Is(err, ErrTemplateReviewRequired) {
+		t.Errorf("expected draft → enabled to require review, got %v", err)
+	}
+	if _, err := svc.Approve(reviewer, tpl.ID); !errors.Is(err, ErrInvalidStatusTransition) {
+		t.Errorf("expected approve of draft to fail, got %v", err)
+	}
+
+	if _, err := svc.SubmitForReview(author, tpl.ID); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	subject := "Changed"
+	if _, err := svc.UpdateTemplate(author, tpl.ID, UpdateTemplateInput{Subject: &subject}); !errors.Is(err, ErrInvalidStatusTransition) {
+		t.Errorf("expected edit under review to fail, got %v", err)
+	}
+	if _, err := svc.Approve(author, tpl.ID); !errors.Is(err, ErrSelfReview) {
+		t.Errorf("expected ErrSelfReview, got %v", err)
+	}
+	if _, err := svc.Approve(context.Background(), tpl.ID); !errors.Is(err, ErrReviewerRequired) {
+		t.Errorf("expected ErrReviewerRequired, got %v", err)
+	}
+	if _, err := svc.Reject(reviewer, tpl.ID, " "); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected empty reason to be rejected, got %v", err)
+	}
+
+	tpl, err = svc.Reject(reviewer, tpl.ID, "缺少退订说明")
+	if err != nil {
+		t.Fatalf("reject: %v", err)
+	}
+	if tpl.Status != model.TemplateStatusDraft || tpl.RejectReason != "缺少退订说明" || tpl.ReviewedBy != "bob" {
+		t.Errorf("unexpected template after reject: %+v", tpl)
+	}
+
+	svc.SubmitForReview(author, tpl.ID)
+	tpl, err = svc.Approve(reviewer, tpl.ID)
+	if err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	if tpl.Status != model.TemplateStatusEnabled || tpl.RejectReason != "" || tpl.ReviewedAt == nil {
+		t.Errorf("unexpected template after approve: %+v", tpl)
+	}
+
+	archived := model.TemplateStatusArchived
+	if _, err := svc.UpdateTemplate(author, tpl.ID, UpdateTemplateInput{Status: &archived}); !errors.Is(err, ErrInvalidStatusTransition) {
+		t.Errorf("expected enabled → archived to fail, got %v", err)
+	}
+	disabled := model.TemplateStatusDisabled
+	svc.UpdateTemplate(author, tpl.ID, UpdateTemplateInput{Status: &disabled})
+	if _, err := svc.UpdateTemplate(author, tpl.ID, UpdateTemplateInput{Status: &archived}); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	if _, err := svc.UpdateTemplate(author, tpl.ID, UpdateTemplateInput{Subject: &subject}); !errors.Is(err, ErrInvalidStatusTransition) {
+		t.Errorf("expected archived template to be read-only, got %v", err)
+	}
+}
+
+func TestService_TemplateAudit(t *testing.T) {
+	type userKey struct{}
+	svc, _ := newTestService(t, WithActorResolver(func(ctx context.Context) string {
+		if user, _ := ctx.Value(userKey{}).(string); user != "" {
+			return user
+		}
+		return ActorFromContext(ctx)
+	}))
+	ctx := context.WithValue(context.Background(), userKey{}, "carol")
+
+	tpl, err := svc.CreateTemplate(ctx, CreateTemplateInput{
+		TriggerCode: "user:registered", Language: "en-US", Name: "Welcome", Subject: "Welcome", BodyHTML: "<p>Hi</p>",
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	subject := "Welcome aboard"
+	if _, err := svc.UpdateTemplate(ctx, tpl.ID, UpdateTemplateInput{Subject: &subject}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := svc.DeleteTemplate(ctx, tpl.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	audits, err := svc.ListTemplateAudit(ctx, tpl.ID)
+	if err != nil {
+		t.Fatalf("list audit: %v", err)
+	}
+	if len(audits) != 3 {
+		t.Fatalf("expected 3 audits, got %d", len(audits))
+	}
+	for _, a := range audits {
+		if a.Actor != "carol" {
+			t.Errorf("expected actor carol, got %q", a.Actor)
+		}
+	}
+
+	update := audits[1]
+	if update.Action != model.AuditActionUpdate {
+		t.Fatalf("expected update audit, got %s", update.Action)
+	}
+	diff := map[string]model.FieldChange{}
+	for _, c := range update.FieldChanges() {
+		diff[c.Field] = c
+	}
+	if c, ok := diff["subject"]; !ok || c.Before != "Welcome" || c.After != "Welcome aboard" {
+		t.Errorf("expected subject diff, got %+v", update.FieldChanges())
+	}
+	if _, ok := diff["name"]; ok {
+		t.Error("unchanged field should not appear in diff")
+	}
+
+	if audits[2].Action != model.AuditActionCreate || audits[0].Action != model.AuditActionDelete {
+		t.Errorf("unexpected audit actions: %s, %s", audits[2].Action, audits[0].Action)
+	}
+}
+
+func TestService_UpdateTemplateConflict(t *testing.T) {
+	svc, _ := newTestService(t)
+	ctx := context.Background()
+
+	tpl, _ := svc.GetTemplateByTrigger(ctx, "user:registered", "zh-CN")
+	revision := tpl.Revision
+
+	name := "管理员 A 的修改"
+	updated, err := svc.UpdateTemplate(ctx, tpl.ID, UpdateTemplateInput{Name: &name, Revision: &revision})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if updated.Revision != revision+1 {
+		t.Errorf("expected revision %d, got %d", revision+1, updated.Revision)
+	}
+
+	// 管理员 B 基于旧修订号提交
+	name = "管理员 B 的修改"
+	if _, err := svc.UpdateTemplate(ctx, tpl.ID, UpdateTemplateInput{Name: &name, Revision: &revision}); !errors.Is(err, ErrTemplateConflict) {
+		t.Fatalf("expected ErrTemplateConflict, got %v", err)
+	}
+
+	got, _ := svc.GetTemplate(ctx, tpl.ID)
+	if got.Name != "管理员 A 的修改" {
+		t.Errorf("expected first edit to be kept, got %s", got.Name)
+	}
+}
+
+func TestService_Layouts(t *testing.T) {
+	svc, sender := newTestService(t)
+	ctx := WithActor(context.Background(), "alice")
+
+	mustCreate := func(input CreatePartialInput) {
+		t.Helper()
+		if _, err := svc.CreatePartial(ctx, input); err != nil {
+			t.Fatalf("create partial %s/%s: %v", input.Name, input.Language, err)
+		}
+	}
+	mustCreate(CreatePartialInput{Name: "base", Kind: model.PartialKindLayout, BodyHTML: `<div class="brand">{{.Content}}{{template "footer" .}}</div>`})
+	mustCreate(CreatePartialInput{Name: "footer", BodyHTML: `<p>{{.AppName}} 团队</p>`})
+	mustCreate(CreatePartialInput{Name: "footer", Language: "en-US", BodyHTML: `<p>The {{.AppName}} team</p>`})
+
+	// 形成循环引用的片段被拒绝
+	if _, err := svc.CreatePartial(ctx, CreatePartialInput{Name: "header", BodyHTML: `{{template "header" .}}`}); !errors.Is(err, ErrPartialCycle) {
+		t.Errorf("expected ErrPartialCycle, got %v", err)
+	}
+	footer, _ := svc.ListPartials(ctx, PartialFilter{Language: "zh-CN", Kind: model.PartialKindPartial})
+	body := `{{template "base" .}}`
+	if _, err := svc.UpdatePartial(ctx, footer[0].ID, UpdatePartialInput{BodyHTML: &body}); !errors.Is(err, ErrPartialCycle) {
+		t.Errorf("expected ErrPartialCycle on update, got %v", err)
+	}
+
+	// 引用不存在的布局被拒绝
+	missing := "missing"
+	tpl, _ := svc.GetTemplateByTrigger(ctx, "user:registered", "zh-CN")
+	if _, err := svc.UpdateTemplate(ctx, tpl.ID, UpdateTemplateInput{Layout: &missing}); !errors.Is(err, ErrPartialNotFound) {
+		t.Errorf("expected ErrPartialNotFound, got %v", err)
+	}
+
+	en, err := svc.CreateTemplate(ctx, CreateTemplateInput{
+		TriggerCode: "user:registered", Language: "en-US", Subject: "Welcome", BodyHTML: "<p>Hi {{.UserName}}</p>", Layout: "base",
+	})
+	if err != nil {
+		t.Fatalf("create en-US template: %v", err)
+	}
+	approveTemplate(t, svc, en.ID)
+
+	if err := svc.Send(ctx, SendInput{TriggerCode: "user:registered", Language: "en-US", Recipient: "a@example.com", Params: map[string]any{"UserName": "Tom"}}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	msg := sender.Last()
+	if msg.HTMLBody != `<div class="brand"><p>Hi Tom</p><p>The Yogan team</p></div>` {
+		t.Errorf("expected en-US footer inside layout, got %s", msg.HTMLBody)
+	}
+	if msg.TextBody != "Hi Tom\n\nThe Yogan team" {
+		t.Errorf("unexpected text body: %q", msg.TextBody)
+	}
+
+	preview, err := svc.PreviewTemplate(ctx, en.ID)
+	if err != nil {
+		t.Fatalf("preview: %v", err)
+	}
+	if !strings.Contains(preview.BodyHTML, `<div class="brand">`) {
+		t.Errorf("expected preview to use layout, got %s", preview.BodyHTML)
+	}
+}
+
+func TestService_DeletePartialInUse(t *testing.T) {
+	svc, _ := newTestService(t)
+	ctx := WithActor(context.Background(), "alice")
+
+	create := func(input CreatePartialInput) *model.Partial {
+		t.Helper()
+		p, err := svc.CreatePartial(ctx, input)
+		if err != nil {
+			t.Fatalf("create partial %s/%s: %v", input.Name, input.Language, err)
+		}
+		return p
+	}
+	base := create(CreatePartialInput{Name: "base", Kind: model.PartialKindLayout, BodyHTML: `<div>{{.Content}}{{template "footer" .}}</div>`})
+	footer := create(CreatePartialInput{Name: "footer", BodyHTML: `<p>{{.AppName}} 团队</p>`})
+	footerEN := create(CreatePartialInput{Name: "footer", Language: "en-US", BodyHTML: `<p>The {{.AppName}} team</p>`})
+	signature := create(CreatePartialInput{Name: "signature", BodyHTML: `<p>--</p>`})
+
+	en, err := svc.CreateTemplate(ctx, CreateTemplateInput{
+		TriggerCode: "user:registered", Language: "en-US", Subject: "Welcome", BodyHTML: "<p>Hi {{.UserName}}</p>", Layout: "base",
+	})
+	if err != nil {
+		t.Fatalf("create en-US template: %v", err)
+	}
+	approveTemplate(t, svc, en.ID)
+
+	// 未被引用的片段可以删除
+	if err := svc.DeletePartial(ctx, signature.ID); err != nil {
+		t.Errorf("delete unused partial: %v", err)
+	}
+	// en-US 版本删除后回退到 zh-CN 版本，不影响引用
+	if err := svc.DeletePartial(ctx, footerEN.ID); err != nil {
+		t.Errorf("delete partial with fallback: %v", err)
+	}
+	// 被布局引用的片段、被模板使用的布局不能删除
+	if err := svc.DeletePartial(ctx, footer.ID); !errors.Is(err, ErrPartialInUse) {
+		t.Errorf("expected ErrPartialInUse for footer, got %v", err)
+	}
+	if err := svc.DeletePartial(ctx, base.ID); !errors.Is(err, ErrPartialInUse) {
+		t.Errorf("expected ErrPartialInUse for layout, got %v", err)
+	}
+
+	// 模板不再使用布局后可以删除（已发布版本仍引用时不行）
+	none := ""
+	updated, err := svc.UpdateTemplate(ctx, en.ID, UpdateTemplateInput{Layout: &none})
+	if err != nil {
+		t.Fatalf("update template: %v", err)
+	}
+	if err := svc.DeletePartial(ctx, base.ID); !errors.Is(err, ErrPartialInUse) {
+		t.Errorf("expected published version to keep layout in use, got %v", err)
+	}
+	for _, status := range []model.TemplateStatus{model.TemplateStatusDisabled, model.TemplateStatusDraft} {
+		if _, err := svc.UpdateTemplate(ctx, en.ID, UpdateTemplateInput{Status: &status}); err != nil {
+			t.Fatalf("update status %s: %v", status, err)
+		}
+	}
+	if _, err := svc.PublishVersion(ctx, en.ID, updated.LatestVersion); err != nil {
+		t.Fatalf("publish version: %v", err)
+	}
+	approveTemplate(t, svc, en.ID)
+	if err := svc.DeletePartial(ctx, base.ID); err != nil {
+		t.Errorf("delete unused layout: %v", err)
+	}
+	if err := svc.DeletePartial(ctx, footer.ID); err != nil {
+		t.Errorf("delete footer after layout: %v", err)
+	}
+}
+
+// countingTemplateRepository 统计 GetActiveTemplate 调用次数
+type countingTemplateRepository struct {
+	TemplateRepository
+	activeCalls int
+}
+
+func (r *countingTemplateRepository) GetActiveTemplate(ctx context.Context, triggerCode, language string) (*model.Template, error) {
+	r.activeCalls++
+	return r.TemplateRepository.GetActiveTemplate(ctx, triggerCode, language)
+}
+
+func TestService_TemplateCache(t *testing.T) {
+	repo := &countingTemplateRepository{TemplateRepository: NewMemoryTemplateRepository()}
+	svc, sender := newTestService(t, WithTemplateRepository(repo), WithTemplateCache(16, time.Minute))
+	ctx := WithActor(context.Background(), "alice")
+
+	send := func() error {
+		return svc.Send(ctx, SendInput{TriggerCode: "user:registered", Recipient: "a@example.com", Params: map[string]any{"UserName": "张三"}})
+	}
+	for i := 0; i < 3; i++ {
+		if err := send(); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+	if repo.activeCalls != 1 {
+		t.Errorf("expected 1 repository lookup, got %d", repo.activeCalls)
+	}
+
+	// 片段变更立即生效
+	if _, err := svc.CreatePartial(ctx, CreatePartialInput{Name: "base", Kind: model.PartialKindLayout, BodyHTML: `<div>{{.Content}}</div>`}); err != nil {
+		t.Fatalf("create layout: %v", err)
+	}
+	tpl, _ := svc.GetTemplateByTrigger(ctx, "user:registered", "zh-CN")
+	disabled, draft, layout := model.TemplateStatusDisabled, model.TemplateStatusDraft, "base"
+	svc.UpdateTemplate(ctx, tpl.ID, UpdateTemplateInput{Status: &disabled})
+
+	// 模板写操作使缓存失效：停用后不可再发送
+	if err := send(); !errors.Is(err, ErrTemplateNotFound) {
+		t.Fatalf("expected ErrTemplateNotFound after disable, got %v", err)
+	}
+
+	svc.UpdateTemplate(ctx, tpl.ID, UpdateTemplateInput{Status: &draft})
+	if _, err := svc.UpdateTemplate(ctx, tpl.ID, UpdateTemplateInput{Layout: &layout, Publish: true}); err != nil {
+		t.Fatalf("set layout: %v", err)
+	}
+	approveTemplate(t, svc, tpl.ID)
+	if err := send(); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if got := sender.Last().HTMLBody; got != "<div><p>Hi 张三</p></div>" {
+		t.Errorf("expected layout to apply, got %s", got)
+	}
+
+	body := `<section>{{.Content}}</section>`
+	layouts, _ := svc.ListPartials(ctx, PartialFilter{Kind: model.PartialKindLayout})
+	if _, err := svc.UpdatePartial(ctx, layouts[0].ID, UpdatePartialInput{BodyHTML: &body}); err != nil {
+		t.Fatalf("update layout: %v", err)
+	}
+	send()
+	if got := sender.Last().HTMLBody; got != "<section><p>Hi 张三</p></section>" {
+		t.Errorf("expected updated layout, got %s", got)
+	}
+}
+
+func TestService_TemplateCachePurgedAfterCommit(t *testing.T) {
+	db := newTestDB(t)
+	svc, _ := newGormTestService(t, db, WithTemplateCache(16, 0))
+	ctx := WithActor(context.Background(), "alice")
+	repo := svc.templateRepo.(*cachingTemplateRepository)
+
+	send := func() error {
+		return svc.Send(ctx, SendInput{TriggerCode: "user:registered", Recipient: "a@example.com", Params: map[string]any{"UserName": "张三"}})
+	}
+	if err := send(); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	// 模拟并发请求在事务内更新模板之后、提交之前（写审计记录时）读取并缓存了旧数据
+	key := "user:registered\x00zh-CN"
+	stale, ok := repo.cache.Get(key)
+	if !ok {
+		t.Fatal("expected active template to be cached")
+	}
+	err := db.Callback().Create().After("gorm:create").Register("test:stale_read", func(tx *gorm.DB) {
+		if tx.Statement.Table == (model.TemplateAudit{}).TableName() {
+			repo.cache.Add(key, stale)
+		}
+	})
+	if err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+
+	disabled := model.TemplateStatusDisabled
+	tpl, _ := svc.GetTemplateByTrigger(ctx, "user:registered", "zh-CN")
+	if _, err := svc.UpdateTemplate(ctx, tpl.ID, UpdateTemplateInput{Status: &disabled}); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	if err := send(); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("expected ErrTemplateNotFound after commit, got %v", err)
+	}
+}
+
+func BenchmarkService_Send(b *testing.B) {
+	run := func(b *testing.B, opts ...Option) {
+		db := newTestDB(b)
+		opts = append([]Option{
+			WithTemplateRepository(NewGormTemplateRepository(db)),
+			WithTemplateVersionRepository(NewGormTemplateVersionRepository(db)),
+			WithTemplateAuditRepository(NewGormTemplateAuditRepository(db)),
+			WithPartialRepository(NewGormPartialRepository(db)),
+			WithSendLogRepository(NewGormSendLogRepository(db)),
+			WithSendJobRepository(NewGormSendJobRepository(db)),
+		}, opts...)
+		svc, sender := newTestService(b, opts...)
+		ctx := context.Background()
+		input := SendInput{TriggerCode: "user:registered", Recipient: "a@example.com", Params: map[string]any{"UserName": "张三"}}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := svc.Send(ctx, input); err != nil {
+				b.Fatal(err)
+			}
+			if i%1000 == 0 {
+				sender.Reset()
+			}
+		}
+	}
+
+	b.Run("uncached", func(b *testing.B) {
+		run(b, WithEngine(NewTemplateEngine(WithParseCacheSize(0))))
+	})
+	b.Run("cached", func(b *testing.B) {
+		run(b, WithTemplateCache(256, time.Minute))
+	})
+}
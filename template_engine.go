@@ -2,41 +2,446 @@ package email_notification
 
 import (
 	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"sync"
 	"text/template"
+	"text/template/parse"
+	"time"
 )
 
+// TrustedParams 声明为可信（Param.Trusted）的参数名，用于 RenderOptions.Trusted
+func TrustedParams(defs []Param) map[string]bool {
+	trusted := make(map[string]bool)
+	for _, def := range defs {
+		if def.Trusted {
+			trusted[def.Name] = true
+		}
+	}
+	return trusted
+}
+
+// DefaultParseCacheSize 默认解析缓存容量（已解析模板数）
+const DefaultParseCacheSize = 512
+
 // TemplateEngine 模板渲染引擎
-type TemplateEngine struct{}
+// 主题与纯文本正文使用 text/template，HTML 正文使用 html/template 按上下文自动转义
+// 解析结果按 LRU 缓存，相同模板重复渲染时跳过解析
+// 内置格式化函数（formatDate、formatMoney 等）按渲染语言绑定，见 template_funcs.go
+type TemplateEngine struct {
+	cache  *lruCache[string, any] // 缓存键 → *template.Template 或 *htmltemplate.Template，nil 表示不缓存
+	now    func() time.Time       // timeAgo 使用的当前时间
+	mu     sync.RWMutex
+	custom map[string]any // RegisterFunc 注册的自定义函数
+}
+
+// EngineOption 模板引擎配置项
+type EngineOption func(e *TemplateEngine)
+
+// WithParseCacheSize 设置解析缓存容量（0 表示关闭缓存）
+func WithParseCacheSize(size int) EngineOption {
+	return func(e *TemplateEngine) {
+		e.cache = nil
+		if size > 0 {
+			e.cache = newLRUCache[string, any](size, 0)
+		}
+	}
+}
+
+// WithNow 设置当前时间来源（timeAgo 计算相对时间）
+func WithNow(now func() time.Time) EngineOption {
+	return func(e *TemplateEngine) {
+		e.now = now
+	}
+}
 
 // NewTemplateEngine 创建模板引擎
-func NewTemplateEngine() *TemplateEngine {
-	return &TemplateEngine{}
+func NewTemplateEngine(opts ...EngineOption) *TemplateEngine {
+	e := &TemplateEngine{
+		cache:  newLRUCache[string, any](DefaultParseCacheSize, 0),
+		now:    time.Now,
+		custom: make(map[string]any),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// RegisterFunc 注册自定义模板函数（同名覆盖内置格式化函数，safeHTML/safeURL 不可覆盖）
+// 函数须返回 1 个值，或 1 个值和 error；注册后清空解析缓存
+func (e *TemplateEngine) RegisterFunc(name string, fn any) error {
+	if err := checkCustomFunc(name, fn); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	e.custom[name] = fn
+	e.mu.Unlock()
+
+	if e.cache != nil {
+		e.cache.Purge()
+	}
+	return nil
+}
+
+// textFuncMap 纯文本模式函数表：语言格式化函数 < 自定义函数 < 安全函数
+func (e *TemplateEngine) textFuncMap(language string) template.FuncMap {
+	funcs := template.FuncMap(e.baseFuncs(language))
+	for name, fn := range textFuncs {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+// htmlFuncMap HTML 模式函数表
+func (e *TemplateEngine) htmlFuncMap(language string) htmltemplate.FuncMap {
+	funcs := htmltemplate.FuncMap(e.baseFuncs(language))
+	for name, fn := range htmlFuncs {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+func (e *TemplateEngine) baseFuncs(language string) map[string]any {
+	funcs := localeFuncs(language, e.now)
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for name, fn := range e.custom {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+// textFuncs 纯文本模式下 safeHTML/safeURL 原样输出，保证同一模板两种模式均可解析
+var textFuncs = template.FuncMap{
+	"safeHTML": func(v any) string { return fmt.Sprint(v) },
+	"safeURL":  func(v any) string { return fmt.Sprint(v) },
+}
+
+// htmlFuncs HTML 模式下 safeHTML/safeURL 跳过转义
+// 参数保持原值（if、eq、range 等行为不变），是否可信按参数名在执行前检查，见 checkSafeUses
+var htmlFuncs = htmltemplate.FuncMap{
+	"safeHTML": func(v any) htmltemplate.HTML { return htmltemplate.HTML(fmt.Sprint(v)) },
+	"safeURL":  func(v any) htmltemplate.URL { return htmltemplate.URL(fmt.Sprint(v)) },
+}
+
+// htmlEntry 已解析的 HTML 模板及其中 safeHTML/safeURL 的用法
+type htmlEntry struct {
+	tmpl     *htmltemplate.Template
+	safeUses []safeUse
+}
+
+// safeUse 一处 safeHTML/safeURL 调用，param 为作用的参数名（非参数引用时为空）
+type safeUse struct {
+	fn    string
+	param string
+}
+
+// RenderOptions 渲染选项
+type RenderOptions struct {
+	Partials map[string]string // 可通过 {{template "name" .}} 引用的片段与布局（名称 → 内容）
+	Layout   string            // 包裹正文的布局名称（需在 Partials 中），正文渲染结果通过 {{.Content}} 注入
+	CacheKey string            // 解析缓存键（如模板 ID + 版本），须随模板或片段内容变化；为空时按内容摘要缓存
+	Language string            // 格式化函数使用的语言（如 zh-CN、en-US），为空时按 zh-CN
+	Trusted  map[string]bool   // 可信参数名（见 TrustedParams），HTML 模式下 safeHTML/safeURL 只能用于这些参数
 }
 
-// Render 渲染模板
+// Render 渲染模板（纯文本，用于主题与纯文本正文）
 func (e *TemplateEngine) Render(templateStr string, params map[string]any) (string, error) {
-	tmpl, err := template.New("email").Parse(templateStr)
-	if err != nil {
-		return "", ErrTemplateRender.Wrap(err)
+	return e.RenderWith(templateStr, params, RenderOptions{})
+}
+
+// RenderHTML 渲染 HTML 模板（上下文自动转义）
+func (e *TemplateEngine) RenderHTML(templateStr string, params map[string]any) (string, error) {
+	return e.RenderHTMLWith(templateStr, params, RenderOptions{})
+}
+
+// RenderWith 渲染纯文本模板，支持片段引用与布局包裹
+func (e *TemplateEngine) RenderWith(templateStr string, params map[string]any, opts RenderOptions) (string, error) {
+	body, err := e.executeText(templateStr, params, opts, scopedCacheKey(opts.CacheKey, "text", opts.Language, ""))
+	if err != nil || opts.Layout == "" {
+		return body, err
+	}
+
+	layout, ok := opts.Partials[opts.Layout]
+	if !ok {
+		return "", ErrTemplateRender.WithMsg("布局不存在: " + opts.Layout)
+	}
+	return e.executeText(layout, withContent(params, body), opts, scopedCacheKey(opts.CacheKey, "text", opts.Language, opts.Layout))
+}
+
+// RenderHTMLWith 渲染 HTML 模板，支持片段引用与布局包裹（正文已转义，作为 HTML 注入布局）
+func (e *TemplateEngine) RenderHTMLWith(templateStr string, params map[string]any, opts RenderOptions) (string, error) {
+	body, err := e.executeHTML(templateStr, params, opts, scopedCacheKey(opts.CacheKey, "html", opts.Language, ""))
+	if err != nil || opts.Layout == "" {
+		return body, err
+	}
+
+	layout, ok := opts.Partials[opts.Layout]
+	if !ok {
+		return "", ErrTemplateRender.WithMsg("布局不存在: " + opts.Layout)
+	}
+	return e.executeHTML(layout, withContent(params, htmltemplate.HTML(body)), opts, scopedCacheKey(opts.CacheKey, "html", opts.Language, opts.Layout))
+}
+
+func (e *TemplateEngine) executeText(templateStr string, params map[string]any, opts RenderOptions, key string) (string, error) {
+	partials := opts.Partials
+	if key == "" {
+		key = contentCacheKey("text", opts.Language, templateStr, partials)
+	}
+
+	tmpl, ok := e.cached(key).(*template.Template)
+	if !ok {
+		names, err := ResolvePartials(templateStr, partials)
+		if err != nil {
+			return "", err
+		}
+
+		tmpl, err = template.New(rootTemplateName).Funcs(e.textFuncMap(opts.Language)).Parse(templateStr)
+		if err != nil {
+			return "", ErrTemplateRender.Wrap(err)
+		}
+		for _, name := range names {
+			if _, err := tmpl.New(name).Parse(partials[name]); err != nil {
+				return "", ErrTemplateRender.WithMsg("片段 " + name + " 解析失败").Wrap(err)
+			}
+		}
+		e.store(key, tmpl)
 	}
 
 	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, params); err != nil {
+	if err := tmpl.ExecuteTemplate(&buf, rootTemplateName, params); err != nil {
 		return "", ErrTemplateRender.Wrap(err)
 	}
+	return buf.String(), nil
+}
+
+func (e *TemplateEngine) executeHTML(templateStr string, params map[string]any, opts RenderOptions, key string) (string, error) {
+	partials := opts.Partials
+	if key == "" {
+		key = contentCacheKey("html", opts.Language, templateStr, partials)
+	}
+
+	entry, ok := e.cached(key).(*htmlEntry)
+	if !ok {
+		names, err := ResolvePartials(templateStr, partials)
+		if err != nil {
+			return "", err
+		}
 
+		tmpl, err := htmltemplate.New(rootTemplateName).Funcs(e.htmlFuncMap(opts.Language)).Parse(templateStr)
+		if err != nil {
+			return "", ErrTemplateRender.Wrap(err)
+		}
+		for _, name := range names {
+			if _, err := tmpl.New(name).Parse(partials[name]); err != nil {
+				return "", ErrTemplateRender.WithMsg("片段 " + name + " 解析失败").Wrap(err)
+			}
+		}
+
+		// 首次执行前收集（执行时 html/template 会改写语法树）
+		collector := &safeUseCollector{trees: make(map[string]*parse.Tree), visited: make(map[string]bool)}
+		for _, t := range tmpl.Templates() {
+			if t.Tree != nil {
+				collector.trees[t.Name()] = t.Tree
+			}
+		}
+		collector.template(rootTemplateName, rootScope)
+		entry = &htmlEntry{tmpl: tmpl, safeUses: collector.uses}
+		e.store(key, entry)
+	}
+	if err := checkSafeUses(entry.safeUses, opts.Trusted); err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := entry.tmpl.ExecuteTemplate(&buf, rootTemplateName, params); err != nil {
+		return "", ErrTemplateRender.Wrap(err)
+	}
 	return buf.String(), nil
 }
 
+func (e *TemplateEngine) cached(key string) any {
+	if e.cache == nil {
+		return nil
+	}
+	tmpl, _ := e.cache.Get(key)
+	return tmpl
+}
+
+func (e *TemplateEngine) store(key string, tmpl any) {
+	if e.cache != nil {
+		e.cache.Add(key, tmpl)
+	}
+}
+
+// checkSafeUses safeHTML/safeURL 只能用于可信参数
+func checkSafeUses(uses []safeUse, trusted map[string]bool) error {
+	for _, use := range uses {
+		if use.param == "" || !trusted[use.param] {
+			return ErrTemplateRender.WithMsg(use.fn + " 只能用于声明为可信的参数: " + use.param)
+		}
+	}
+	return nil
+}
+
+// paramScope 当前位置的 dot 与 $ 是否指向模板参数
+// range / with 内部 dot 改变；片段的 dot 与 $ 均为调用处传入的值
+type paramScope struct {
+	dot    bool
+	dollar bool
+}
+
+// rootScope 根模板（正文、布局）的作用域
+var rootScope = paramScope{dot: true, dollar: true}
+
+// inner range / with 内部的作用域
+func (s paramScope) inner() paramScope {
+	return paramScope{dollar: s.dollar}
+}
+
+// call 以 pipe 的值调用片段（{{template "p" pipe}}）时片段内的作用域
+// 仅传入 . 或 $ 且其指向模板参数时片段内仍为模板参数，如 {{template "p" .User}} 的片段内不是
+func (s paramScope) call(p *parse.PipeNode) paramScope {
+	if p == nil || len(p.Decl) > 0 || len(p.Cmds) != 1 || len(p.Cmds[0].Args) != 1 {
+		return paramScope{}
+	}
+	switch n := p.Cmds[0].Args[0].(type) {
+	case *parse.DotNode:
+		return paramScope{dot: s.dot, dollar: s.dot}
+	case *parse.VariableNode:
+		if len(n.Ident) == 1 && n.Ident[0] == "$" {
+			return paramScope{dot: s.dollar, dollar: s.dollar}
+		}
+	}
+	return paramScope{}
+}
+
+// safeUseCollector 从根模板出发收集 safeHTML/safeURL 调用，片段按调用处的作用域遍历
+type safeUseCollector struct {
+	trees   map[string]*parse.Tree
+	visited map[string]bool // 已遍历的 模板名+作用域
+	uses    []safeUse
+}
+
+func (c *safeUseCollector) template(name string, scope paramScope) {
+	key := fmt.Sprintf("%s|%t|%t", name, scope.dot, scope.dollar)
+	if c.visited[key] {
+		return
+	}
+	c.visited[key] = true
+	if tree := c.trees[name]; tree != nil {
+		c.walk(tree.Root, scope)
+	}
+}
+
+func (c *safeUseCollector) walk(node parse.Node, scope paramScope) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			c.walk(child, scope)
+		}
+	case *parse.ActionNode:
+		c.pipe(n.Pipe, scope)
+	case *parse.IfNode:
+		c.pipe(n.Pipe, scope)
+		c.walk(n.List, scope)
+		c.walk(n.ElseList, scope)
+	case *parse.RangeNode:
+		c.pipe(n.Pipe, scope)
+		c.walk(n.List, scope.inner())
+		c.walk(n.ElseList, scope)
+	case *parse.WithNode:
+		c.pipe(n.Pipe, scope)
+		c.walk(n.List, scope.inner())
+		c.walk(n.ElseList, scope)
+	case *parse.TemplateNode:
+		c.pipe(n.Pipe, scope)
+		c.template(n.Name, scope.call(n.Pipe))
+	}
+}
+
+func (c *safeUseCollector) pipe(p *parse.PipeNode, scope paramScope) {
+	if p == nil {
+		return
+	}
+	for i, cmd := range p.Cmds {
+		for _, arg := range cmd.Args {
+			if sub, ok := arg.(*parse.PipeNode); ok {
+				c.pipe(sub, scope)
+			}
+		}
+		if len(cmd.Args) == 0 || !isSafeFunc(cmd.Args[0]) {
+			continue
+		}
+		// {{safeHTML .X}} 或 {{.X | safeHTML}}
+		var target parse.Node
+		switch {
+		case len(cmd.Args) == 2:
+			target = cmd.Args[1]
+		case len(cmd.Args) == 1 && i > 0 && len(p.Cmds[i-1].Args) == 1:
+			target = p.Cmds[i-1].Args[0]
+		}
+		c.uses = append(c.uses, safeUse{fn: cmd.Args[0].(*parse.IdentifierNode).Ident, param: paramName(target, scope)})
+	}
+}
+
+// scopedCacheKey 调用方缓存键加上渲染模式、语言与布局（正文与布局分别缓存，函数按语言绑定）
+func scopedCacheKey(key, mode, language, layout string) string {
+	if key == "" {
+		return ""
+	}
+	return mode + "|" + language + "|" + key + "|" + layout
+}
+
+// contentCacheKey 按模板与片段内容计算缓存键
+func contentCacheKey(mode, language, templateStr string, partials map[string]string) string {
+	return mode + "|" + language + "|#" + digestPartials(map[string]string{rootTemplateName: templateStr}, partials)
+}
+
+// Validate 校验模板语法（HTML 与纯文本两种模式，函数须已注册）
+func (e *TemplateEngine) Validate(templateStr string) error {
+	if _, err := htmltemplate.New(rootTemplateName).Funcs(e.htmlFuncMap("")).Parse(templateStr); err != nil {
+		return err
+	}
+	_, err := template.New(rootTemplateName).Funcs(e.textFuncMap("")).Parse(templateStr)
+	return err
+}
+
+// withContent 复制参数并注入布局正文
+func withContent(params map[string]any, content any) map[string]any {
+	result := make(map[string]any, len(params)+1)
+	for k, v := range params {
+		result[k] = v
+	}
+	result["Content"] = content
+	return result
+}
+
 // Preview 预览模板（使用示例值）
 func (e *TemplateEngine) Preview(templateStr string, params []Param) (string, error) {
-	exampleParams := make(map[string]any)
+	return e.Render(templateStr, exampleParams(params))
+}
+
+// PreviewHTML 预览 HTML 模板（使用示例值）
+func (e *TemplateEngine) PreviewHTML(templateStr string, params []Param) (string, error) {
+	return e.RenderHTMLWith(templateStr, exampleParams(params), RenderOptions{Trusted: TrustedParams(params)})
+}
+
+// exampleParams 构建示例参数（无示例值时保留占位符）
+func exampleParams(params []Param) map[string]any {
+	result := make(map[string]any)
 	for _, p := range params {
 		if p.Example != "" {
-			exampleParams[p.Name] = p.Example
+			result[p.Name] = p.Example
 		} else {
-			exampleParams[p.Name] = "{{." + p.Name + "}}"
+			result[p.Name] = "{{." + p.Name + "}}"
 		}
 	}
-	return e.Render(templateStr, exampleParams)
+	return result
 }
@@ -1,6 +1,8 @@
 package email_notification
 
 import (
+	"errors"
+	"fmt"
 	"strings"
 	"testing"
 )
@@ -93,3 +95,260 @@ func TestTemplateEngine_Preview(t *testing.T) {
 		t.Error("expected result to contain placeholder for NoExample")
 	}
 }
+
+func TestTemplateEngine_RenderHTML(t *testing.T) {
+	engine := NewTemplateEngine()
+
+	tests := []struct {
+		name     string
+		template string
+		params   map[string]any
+		trusted  map[string]bool
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "escape html",
+			template: "<p>Hi {{.UserName}}</p>",
+			params:   map[string]any{"UserName": "<script>alert(1)</script>"},
+			expected: "<p>Hi &lt;script&gt;alert(1)&lt;/script&gt;</p>",
+		},
+		{
+			name:     "unsafe url",
+			template: `<a href="{{.Link}}">link</a>`,
+			params:   map[string]any{"Link": "javascript:alert(1)"},
+			expected: `<a href="#ZgotmplZ">link</a>`,
+		},
+		{
+			name:     "trusted safeHTML",
+			template: "<div>{{safeHTML .Banner}}</div>",
+			params:   map[string]any{"Banner": "<b>Sale</b>"},
+			trusted:  map[string]bool{"Banner": true},
+			expected: "<div><b>Sale</b></div>",
+		},
+		{
+			name:     "untrusted safeHTML",
+			template: "<div>{{safeHTML .Banner}}</div>",
+			params:   map[string]any{"Banner": "<b>Sale</b>"},
+			wantErr:  true,
+		},
+		{
+			name:     "untrusted piped safeURL",
+			template: `<a href="{{.Link | safeURL}}">link</a>`,
+			params:   map[string]any{"Link": "javascript:alert(1)"},
+			trusted:  map[string]bool{"Banner": true},
+			wantErr:  true,
+		},
+		{
+			name:     "safeHTML on range item",
+			template: "{{range .Items}}{{safeHTML .}}{{end}}",
+			params:   map[string]any{"Items": []string{"<b>a</b>"}},
+			trusted:  map[string]bool{"Items": true},
+			wantErr:  true,
+		},
+		{
+			name:     "trusted params keep raw values",
+			template: `{{if .URL}}<a href="{{safeURL .URL}}">link</a>{{end}}{{if eq .Kind "sale"}}<i>{{safeHTML .Kind}}</i>{{end}}{{range .Items}}<b>{{.}}</b>{{end}}{{if .Empty}}empty{{end}}`,
+			params:   map[string]any{"URL": "https://example.com/a?b=1", "Kind": "sale", "Items": []string{"x", "y"}, "Empty": ""},
+			trusted:  map[string]bool{"URL": true, "Kind": true, "Items": true, "Empty": true},
+			expected: `<a href="https://example.com/a?b=1">link</a><i>sale</i><b>x</b><b>y</b>`,
+		},
+		{
+			name:     "empty trusted param is falsy",
+			template: `{{if .URL}}<a href="{{safeURL .URL}}">link</a>{{else}}none{{end}}`,
+			params:   map[string]any{"URL": ""},
+			trusted:  map[string]bool{"URL": true},
+			expected: "none",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := engine.RenderHTMLWith(tt.template, tt.params, RenderOptions{Trusted: tt.trusted})
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if result != tt.expected {
+				t.Errorf("expected '%s', got '%s'", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestTrustedParams(t *testing.T) {
+	defs := []Param{
+		{Name: "Banner", Trusted: true},
+		{Name: "UserName"},
+	}
+
+	trusted := TrustedParams(defs)
+	if !trusted["Banner"] || trusted["UserName"] || len(trusted) != 1 {
+		t.Errorf("expected only Banner to be trusted, got %v", trusted)
+	}
+}
+
+func TestTemplateEngine_RenderHTMLWith(t *testing.T) {
+	engine := NewTemplateEngine()
+	partials := map[string]string{
+		"footer":      `<footer>{{template "unsubscribe" .}}</footer>`,
+		"unsubscribe": `<a href="{{.UnsubscribeURL}}">退订</a>`,
+		"base":        `<html><body>{{.Content}}{{template "footer" .}}</body></html>`,
+	}
+	params := map[string]any{"UserName": "<b>张三</b>", "UnsubscribeURL": "https://example.com/u?a=1&b=2"}
+
+	got, err := engine.RenderHTMLWith(`<p>Hi {{.UserName}}</p>`, params, RenderOptions{Partials: partials, Layout: "base"})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	expected := `<html><body><p>Hi &lt;b&gt;张三&lt;/b&gt;</p><footer><a href="https://example.com/u?a=1&amp;b=2">退订</a></footer></body></html>`
+	if got != expected {
+		t.Errorf("got %s\nwant %s", got, expected)
+	}
+
+	text, err := engine.RenderWith(`Hi {{.UserName}}`, params, RenderOptions{
+		Partials: map[string]string{"base": "{{.Content}}\n--\n退订: {{.UnsubscribeURL}}"},
+		Layout:   "base",
+	})
+	if err != nil {
+		t.Fatalf("render text: %v", err)
+	}
+	if text != "Hi <b>张三</b>\n--\n退订: https://example.com/u?a=1&b=2" {
+		t.Errorf("unexpected text: %q", text)
+	}
+}
+
+func TestTemplateEngine_SafeUsesInPartials(t *testing.T) {
+	engine := NewTemplateEngine()
+	partials := map[string]string{
+		"banner": `{{safeHTML .Banner}}`,
+		"dollar": `{{safeHTML $.Banner}}`,
+		"outer":  `<div>{{template "banner" .}}</div>`,
+	}
+	params := map[string]any{
+		"Banner": "<b>Sale</b>",
+		"User":   map[string]any{"Banner": "<script>x</script>"},
+		"Users":  []map[string]any{{"Banner": "<script>x</script>"}},
+	}
+	trusted := map[string]bool{"Banner": true, "User": true, "Users": true}
+
+	tests := []struct {
+		name     string
+		template string
+		wantErr  bool
+	}{
+		{name: "partial with params", template: `{{template "banner" .}}`},
+		{name: "nested partial with params", template: `{{template "outer" $}}`},
+		{name: "partial with field", template: `{{template "banner" .User}}`, wantErr: true},
+		{name: "dollar in partial with field", template: `{{template "dollar" .User}}`, wantErr: true},
+		{name: "partial in range", template: `{{range .Users}}{{template "banner" .}}{{end}}`, wantErr: true},
+	}
+
+	// 片段以非模板参数调用时，片段内的 .Banner / $.Banner 不是可信参数 Banner
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := engine.RenderHTMLWith(tt.template, params, RenderOptions{Partials: partials, Trusted: trusted})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestResolvePartials(t *testing.T) {
+	partials := map[string]string{
+		"a":     `{{template "b" .}}`,
+		"b":     `{{if .X}}{{template "c" .}}{{end}}`,
+		"c":     `{{define "local"}}x{{end}}{{template "local"}}`,
+		"loop1": `{{range .Items}}{{template "loop2" .}}{{end}}`,
+		"loop2": `{{with .}}{{template "loop1" .}}{{end}}`,
+	}
+
+	names, err := ResolvePartials(`{{template "a" .}}`, partials)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if strings.Join(names, ",") != "c,b,a" {
+		t.Errorf("expected dependency order c,b,a, got %v", names)
+	}
+
+	if _, err := ResolvePartials(`{{template "loop1" .}}`, partials); !errors.Is(err, ErrPartialCycle) {
+		t.Errorf("expected ErrPartialCycle, got %v", err)
+	}
+	if _, err := ResolvePartials(`{{template "missing" .}}`, partials); !errors.Is(err, ErrPartialNotFound) {
+		t.Errorf("expected ErrPartialNotFound, got %v", err)
+	}
+
+	if err := CheckPartialCycle("a", map[string]string{"a": `{{template "future" .}}`}); err != nil {
+		t.Errorf("missing partials should be allowed when checking cycles, got %v", err)
+	}
+	if err := CheckPartialCycle("self", map[string]string{"self": `{{template "self" .}}`}); !errors.Is(err, ErrPartialCycle) {
+		t.Errorf("expected self reference to be a cycle, got %v", err)
+	}
+}
+
+func TestTemplateEngine_ParseCache(t *testing.T) {
+	engine := NewTemplateEngine()
+	partials := map[string]string{"footer": "<p>{{.AppName}}</p>"}
+	opts := RenderOptions{Partials: partials, CacheKey: "tpl:1:v1"}
+
+	for i := 0; i < 3; i++ {
+		got, err := engine.RenderHTMLWith(`<p>{{.Name}}</p>{{template "footer" .}}`, map[string]any{"Name": i, "AppName": "Yogan"}, opts)
+		if err != nil {
+			t.Fatalf("render: %v", err)
+		}
+		if want := fmt.Sprintf("<p>%d</p><p>Yogan</p>", i); got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+	if engine.cache.Len() != 1 {
+		t.Errorf("expected 1 cached template, got %d", engine.cache.Len())
+	}
+
+	// 未指定缓存键时按内容缓存，内容变化不会命中旧模板
+	engine.Render("Hello {{.Name}}", map[string]any{"Name": "A"})
+	got, _ := engine.Render("Hi {{.Name}}", map[string]any{"Name": "A"})
+	if got != "Hi A" {
+		t.Errorf("expected content-keyed cache to miss on changed content, got %s", got)
+	}
+	if engine.cache.Len() != 3 {
+		t.Errorf("expected 3 cached templates, got %d", engine.cache.Len())
+	}
+
+	uncached := NewTemplateEngine(WithParseCacheSize(0))
+	if _, err := uncached.Render("Hello", nil); err != nil || uncached.cache != nil {
+		t.Errorf("expected cache to be disabled, err=%v", err)
+	}
+}
+
+func BenchmarkTemplateEngine_RenderHTMLWith(b *testing.B) {
+	partials := map[string]string{
+		"base":   `<html><body><div class="header">{{.AppName}}</div>{{.Content}}{{template "footer" .}}</body></html>`,
+		"footer": `<div class="footer">{{range .Links}}<a href="{{.}}">{{.}}</a>{{end}}<p>&copy; {{.CurrentYear}} {{.AppName}}</p></div>`,
+	}
+	body := `<h1>您好，{{.UserName}}</h1>{{if .Code}}<p>验证码：<b>{{.Code}}</b>，{{.Minutes}} 分钟内有效。</p>{{end}}<p>如非本人操作请忽略。</p>`
+	params := map[string]any{
+		"AppName": "Yogan", "UserName": "张三", "Code": "123456", "Minutes": 10, "CurrentYear": 2026,
+		"Links": []string{"https://example.com/help", "https://example.com/unsubscribe"},
+	}
+
+	run := func(b *testing.B, engine *TemplateEngine, key string) {
+		opts := RenderOptions{Partials: partials, Layout: "base", CacheKey: key}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := engine.RenderHTMLWith(body, params, opts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.Run("uncached", func(b *testing.B) { run(b, NewTemplateEngine(WithParseCacheSize(0)), "") })
+	b.Run("content-key", func(b *testing.B) { run(b, NewTemplateEngine(), "") })
+	b.Run("version-key", func(b *testing.B) { run(b, NewTemplateEngine(), "tpl:1:v1") })
+}
@@ -0,0 +1,469 @@
+package email_notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 模板内置函数（按模板语言格式化）：
+//
+//	{{formatDate .CreatedAt}}            默认 medium 格式，可选 short / medium / long / datetime / time 或 Go 时间布局
+//	{{formatDate "long" .CreatedAt}}
+//	{{formatNumber .Count}}              千分位，可选小数位：{{formatNumber 2 .Rate}}
+//	{{formatMoney "CNY" .Amount}}        货币符号与位置按语言
+//	{{pluralize .Count "item" "items"}}  按语言复数规则选择词形（中日韩不区分单复数）
+//	{{truncate 20 .Title}}               按字符截断并追加省略号
+//	{{.Name | default "客户"}}           空值时使用默认值
+//	{{upper .Code}}
+//	{{urlquery .Keyword}}
+//	{{timeAgo .CreatedAt}}               相对时间：3 分钟前 / 3 minutes ago
+
+// localeFormat 语言格式约定
+type localeFormat struct {
+	dateLayouts  map[string]string // short / medium / long / datetime / time
+	decimal      string
+	group        string
+	symbolSuffix bool // 货币符号置于数字后
+	noPlural     bool // 无复数变化
+	pluralZero   bool // 0 使用单数（如法语）
+	ago          func(n int, unit string, future bool) string
+	justNow      string
+}
+
+var (
+	zhDateLayouts = map[string]string{
+		"short": "2006-01-02", "medium": "2006年1月2日", "long": "2006年1月2日 15:04",
+		"datetime": "2006-01-02 15:04", "time": "15:04",
+	}
+	enDateLayouts = map[string]string{
+		"short": "01/02/2006", "medium": "Jan 2, 2006", "long": "January 2, 2006 3:04 PM",
+		"datetime": "2006-01-02 15:04", "time": "3:04 PM",
+	}
+	euDateLayouts = map[string]string{
+		"short": "02.01.2006", "medium": "2.1.2006", "long": "2.1.2006 15:04",
+		"datetime": "2006-01-02 15:04", "time": "15:04",
+	}
+
+	zhUnits = map[string]string{"second": "秒", "minute": "分钟", "hour": "小时", "day": "天", "month": "个月", "year": "年"}
+	jaUnits = map[string]string{"second": "秒", "minute": "分", "hour": "時間", "day": "日", "month": "か月", "year": "年"}
+	koUnits = map[string]string{"second": "초", "minute": "분", "hour": "시간", "day": "일", "month": "개월", "year": "년"}
+
+	deUnits = unitForms{"second": {"Sekunde", "Sekunden"}, "minute": {"Minute", "Minuten"}, "hour": {"Stunde", "Stunden"}, "day": {"Tag", "Tagen"}, "month": {"Monat", "Monaten"}, "year": {"Jahr", "Jahren"}}
+	esUnits = unitForms{"second": {"segundo", "segundos"}, "minute": {"minuto", "minutos"}, "hour": {"hora", "horas"}, "day": {"día", "días"}, "month": {"mes", "meses"}, "year": {"año", "años"}}
+	itUnits = unitForms{"second": {"secondo", "secondi"}, "minute": {"minuto", "minuti"}, "hour": {"ora", "ore"}, "day": {"giorno", "giorni"}, "month": {"mese", "mesi"}, "year": {"anno", "anni"}}
+	frUnits = unitForms{"second": {"seconde", "secondes"}, "minute": {"minute", "minutes"}, "hour": {"heure", "heures"}, "day": {"jour", "jours"}, "month": {"mois", "mois"}, "year": {"an", "ans"}}
+)
+
+// unitForms 相对时间单位的单数与复数形式
+type unitForms map[string][2]string
+
+// phraseAgo 按过去、将来两种句式与单位单复数构建相对时间
+func phraseAgo(past, future string, units unitForms) func(n int, unit string, future bool) string {
+	return func(n int, unit string, isFuture bool) string {
+		word := units[unit][1]
+		if n == 1 {
+			word = units[unit][0]
+		}
+		if isFuture {
+			return fmt.Sprintf(future, n, word)
+		}
+		return fmt.Sprintf(past, n, word)
+	}
+}
+
+// locales 按语言（小写）或基础语言匹配
+var locales = map[string]*localeFormat{
+	"zh": {
+		dateLayouts: zhDateLayouts, decimal: ".", group: ",", noPlural: true, justNow: "刚刚",
+		ago: func(n int, unit string, future bool) string {
+			if future {
+				return fmt.Sprintf("%d %s后", n, zhUnits[unit])
+			}
+			return fmt.Sprintf("%d %s前", n, zhUnits[unit])
+		},
+	},
+	"ja": {
+		dateLayouts: zhDateLayouts, decimal: ".", group: ",", noPlural: true, justNow: "たった今",
+		ago: func(n int, unit string, future bool) string {
+			if future {
+				return fmt.Sprintf("%d%s後", n, jaUnits[unit])
+			}
+			return fmt.Sprintf("%d%s前", n, jaUnits[unit])
+		},
+	},
+	"ko": {
+		dateLayouts: map[string]string{
+			"short": "2006. 1. 2.", "medium": "2006년 1월 2일", "long": "2006년 1월 2일 15:04",
+			"datetime": "2006-01-02 15:04", "time": "15:04",
+		},
+		decimal: ".", group: ",", noPlural: true, justNow: "방금",
+		ago: func(n int, unit string, future bool) string {
+			if future {
+				return fmt.Sprintf("%d%s 후", n, koUnits[unit])
+			}
+			return fmt.Sprintf("%d%s 전", n, koUnits[unit])
+		},
+	},
+	"en": {
+		dateLayouts: enDateLayouts, decimal: ".", group: ",", justNow: "just now",
+		ago: func(n int, unit string, future bool) string {
+			if n != 1 {
+				unit += "s"
+			}
+			if future {
+				return fmt.Sprintf("in %d %s", n, unit)
+			}
+			return fmt.Sprintf("%d %s ago", n, unit)
+		},
+	},
+	"en-gb": {
+		dateLayouts: map[string]string{
+			"short": "02/01/2006", "medium": "2 Jan 2006", "long": "2 January 2006 15:04",
+			"datetime": "2006-01-02 15:04", "time": "15:04",
+		},
+		decimal: ".", group: ",", justNow: "just now",
+	},
+	"de": {
+		dateLayouts: euDateLayouts, decimal: ",", group: ".", symbolSuffix: true, justNow: "gerade eben",
+		ago: phraseAgo("vor %d %s", "in %d %s", deUnits),
+	},
+	"es": {
+		dateLayouts: euDateLayouts, decimal: ",", group: ".", symbolSuffix: true, justNow: "ahora mismo",
+		ago: phraseAgo("hace %d %s", "dentro de %d %s", esUnits),
+	},
+	"it": {
+		dateLayouts: euDateLayouts, decimal: ",", group: ".", symbolSuffix: true, justNow: "proprio ora",
+		ago: phraseAgo("%d %s fa", "tra %d %s", itUnits),
+	},
+	"fr": {
+		dateLayouts: euDateLayouts, decimal: ",", group: "\u00a0", symbolSuffix: true, pluralZero: true, justNow: "à l'instant",
+		ago: phraseAgo("il y a %d %s", "dans %d %s", frUnits),
+	},
+}
+
+// localeFor 获取语言格式，未指定语言时使用 zh-CN（模块默认语言），未配置的语言使用英文
+func localeFor(language string) *localeFormat {
+	if language == "" {
+		return locales["zh"]
+	}
+	lang := strings.ToLower(strings.ReplaceAll(language, "_", "-"))
+	if l, ok := locales[lang]; ok {
+		return l.withDefaults()
+	}
+	base, _, _ := strings.Cut(lang, "-")
+	if l, ok := locales[base]; ok {
+		return l.withDefaults()
+	}
+	return locales["en"]
+}
+
+// withDefaults 未配置相对时间的语言使用英文
+func (l *localeFormat) withDefaults() *localeFormat {
+	if l.ago != nil {
+		return l
+	}
+	c := *l
+	c.ago = locales["en"].ago
+	if c.justNow == "" {
+		c.justNow = locales["en"].justNow
+	}
+	return &c
+}
+
+// currencies 货币符号与小数位
+var currencies = map[string]struct {
+	symbol   string
+	decimals int
+}{
+	"CNY": {"¥", 2}, "USD": {"$", 2}, "EUR": {"€", 2}, "GBP": {"£", 2},
+	"JPY": {"¥", 0}, "KRW": {"₩", 0}, "HKD": {"HK$", 2}, "TWD": {"NT$", 2},
+}
+
+// reservedFuncNames RegisterFunc 不可覆盖的安全函数
+var reservedFuncNames = map[string]bool{"safeHTML": true, "safeURL": true}
+
+var funcNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// localeFuncs 构建绑定语言的函数表
+func localeFuncs(language string, now func() time.Time) map[string]any {
+	l := localeFor(language)
+	turkic := strings.HasPrefix(strings.ToLower(language), "tr") || strings.HasPrefix(strings.ToLower(language), "az")
+
+	return map[string]any{
+		"formatDate": func(args ...any) (string, error) {
+			style, value, err := styledArgs("formatDate", args)
+			if err != nil {
+				return "", err
+			}
+			t, ok := toTime(value)
+			if !ok {
+				return fmt.Sprint(value), nil
+			}
+			if style == "" {
+				style = "medium"
+			}
+			layout, ok := l.dateLayouts[style]
+			if !ok {
+				layout = style
+			}
+			return t.Format(layout), nil
+		},
+		"formatNumber": func(args ...any) (string, error) {
+			if len(args) == 0 || len(args) > 2 {
+				return "", fmt.Errorf("formatNumber 需要 1 或 2 个参数")
+			}
+			value := args[len(args)-1]
+			f, isInt, ok := toNumber(value)
+			if !ok {
+				return fmt.Sprint(value), nil
+			}
+			decimals := 0
+			if !isInt {
+				decimals = -1
+			}
+			if len(args) == 2 {
+				d, ok := args[0].(int)
+				if !ok {
+					return "", fmt.Errorf("formatNumber 小数位须为整数")
+				}
+				decimals = d
+			}
+			return l.formatNumber(f, decimals), nil
+		},
+		"formatMoney": func(currency string, value any) string {
+			f, _, ok := toNumber(value)
+			if !ok {
+				return fmt.Sprint(value)
+			}
+			code := strings.ToUpper(currency)
+			c, known := currencies[code]
+			if !known {
+				return code + " " + l.formatNumber(f, 2)
+			}
+			amount := l.formatNumber(math.Abs(f), c.decimals)
+			sign := ""
+			if f < 0 {
+				sign = "-"
+			}
+			if l.symbolSuffix {
+				return sign + amount + "\u00a0" + c.symbol
+			}
+			return sign + c.symbol + amount
+		},
+		"pluralize": func(count any, singular string, plural ...string) string {
+			n, _, _ := toNumber(count)
+			if l.noPlural || n == 1 || (l.pluralZero && n == 0) {
+				return singular
+			}
+			if len(plural) > 0 && plural[0] != "" {
+				return plural[0]
+			}
+			return singular + "s"
+		},
+		"truncate": func(length int, value any) string {
+			s := fmt.Sprint(value)
+			if length <= 0 || utf8.RuneCountInString(s) <= length {
+				return s
+			}
+			return string([]rune(s)[:length]) + "…"
+		},
+		"default": func(def any, value ...any) any {
+			if len(value) == 0 || isZeroValue(value[0]) {
+				return def
+			}
+			return value[0]
+		},
+		"upper": func(value any) string {
+			if turkic {
+				return strings.ToUpperSpecial(unicode.TurkishCase, fmt.Sprint(value))
+			}
+			return strings.ToUpper(fmt.Sprint(value))
+		},
+		"urlquery": func(args ...any) string {
+			parts := make([]string, len(args))
+			for i, a := range args {
+				parts[i] = fmt.Sprint(a)
+			}
+			return url.QueryEscape(strings.Join(parts, ""))
+		},
+		"timeAgo": func(value any) string {
+			t, ok := toTime(value)
+			if !ok {
+				return fmt.Sprint(value)
+			}
+			return l.timeAgo(now().Sub(t))
+		},
+	}
+}
+
+// styledArgs 解析 (可选样式, 值) 形式的参数
+func styledArgs(name string, args []any) (string, any, error) {
+	switch len(args) {
+	case 1:
+		return "", args[0], nil
+	case 2:
+		style, ok := args[0].(string)
+		if !ok {
+			return "", nil, fmt.Errorf("%s 的格式参数须为字符串", name)
+		}
+		return style, args[1], nil
+	}
+	return "", nil, fmt.Errorf("%s 需要 1 或 2 个参数", name)
+}
+
+// formatNumber 按语言千分位格式化，decimals < 0 时保留必要的小数位（最多 2 位）
+func (l *localeFormat) formatNumber(f float64, decimals int) string {
+	var s string
+	if decimals < 0 {
+		s = strconv.FormatFloat(f, 'f', 2, 64)
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	} else {
+		s = strconv.FormatFloat(f, 'f', decimals, 64)
+	}
+
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	intPart, frac, hasFrac := strings.Cut(s, ".")
+
+	var b strings.Builder
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(l.group)
+		}
+		b.WriteRune(r)
+	}
+	if hasFrac {
+		b.WriteString(l.decimal)
+		b.WriteString(frac)
+	}
+	return sign + b.String()
+}
+
+// timeAgo 相对时间（d > 0 表示过去）
+func (l *localeFormat) timeAgo(d time.Duration) string {
+	future := d < 0
+	if future {
+		d = -d
+	}
+	if d < 45*time.Second {
+		return l.justNow
+	}
+
+	units := []struct {
+		name string
+		size time.Duration
+	}{
+		{"year", 365 * 24 * time.Hour},
+		{"month", 30 * 24 * time.Hour},
+		{"day", 24 * time.Hour},
+		{"hour", time.Hour},
+		{"minute", time.Minute},
+	}
+	for _, u := range units {
+		if d >= u.size {
+			return l.ago(int(d/u.size), u.name, future)
+		}
+	}
+	return l.ago(int(d/time.Second), "second", future)
+}
+
+// toTime 转换为时间（支持 time.Time、RFC3339 字符串与 Unix 秒）
+func toTime(value any) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case *time.Time:
+		if v != nil {
+			return *v, true
+		}
+	case string:
+		if t, err := time.Parse(time.RFC3339, strings.TrimSpace(v)); err == nil {
+			return t, true
+		}
+		if t, err := time.Parse("2006-01-02", strings.TrimSpace(v)); err == nil {
+			return t, true
+		}
+	default:
+		if f, isInt, ok := toNumber(v); ok && isInt {
+			return time.Unix(int64(f), 0), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// toNumber 转换为数字，isInt 表示原值为整数
+func toNumber(value any) (float64, bool, bool) {
+	switch v := value.(type) {
+	case int, int8, int16, int32, int64:
+		return float64(reflect.ValueOf(v).Int()), true, true
+	case uint, uint8, uint16, uint32, uint64:
+		return float64(reflect.ValueOf(v).Uint()), true, true
+	case float32:
+		return float64(v), false, true
+	case float64:
+		return v, false, true
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return float64(i), true, true
+		}
+		f, err := v.Float64()
+		return f, false, err == nil
+	case string:
+		s := strings.TrimSpace(v)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return float64(i), true, true
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		return f, false, err == nil
+	}
+	return 0, false, false
+}
+
+// isZeroValue 是否为空值（nil、空字符串、零值、空集合）
+func isZeroValue(value any) bool {
+	if value == nil {
+		return true
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return rv.IsNil()
+	}
+	return rv.IsZero()
+}
+
+// checkCustomFunc 校验自定义函数（签名须满足 text/template 要求）
+func checkCustomFunc(name string, fn any) error {
+	if !funcNamePattern.MatchString(name) {
+		return ErrInvalidInput.WithMsg("函数名不合法: " + name)
+	}
+	if reservedFuncNames[name] {
+		return ErrInvalidInput.WithMsg("不能覆盖安全函数: " + name)
+	}
+
+	t := reflect.TypeOf(fn)
+	if t == nil || t.Kind() != reflect.Func {
+		return ErrInvalidInput.WithMsg(name + " 不是函数")
+	}
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	switch {
+	case t.NumOut() == 1:
+	case t.NumOut() == 2 && t.Out(1) == errorType:
+	default:
+		return ErrInvalidInput.WithMsg(name + " 须返回 1 个值，或 1 个值和 error")
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package email_notification
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemplateEngine_Funcs(t *testing.T) {
+	now := time.Date(2026, 3, 15, 10, 30, 0, 0, time.UTC)
+	engine := NewTemplateEngine(WithNow(func() time.Time { return now }))
+
+	params := map[string]any{
+		"At":     time.Date(2026, 3, 1, 9, 5, 0, 0, time.UTC),
+		"Amount": 1234567.5,
+		"Count":  3,
+		"One":    1,
+		"Title":  "一二三四五六七八",
+		"Empty":  "",
+		"Recent": now.Add(-3 * time.Minute),
+		"Query":  "a b&c",
+		"Later":  now.Add(2 * time.Hour),
+	}
+
+	tests := []struct {
+		name     string
+		language string
+		template string
+		expected string
+	}{
+		{"date zh", "zh-CN", `{{formatDate .At}}`, "2026年3月1日"},
+		{"date en short", "en-US", `{{formatDate "short" .At}}`, "03/01/2026"},
+		{"date en-GB", "en-GB", `{{formatDate "medium" .At}}`, "1 Mar 2026"},
+		{"date custom layout", "zh-CN", `{{formatDate "2006/01/02" .At}}`, "2026/03/01"},
+		{"date rfc3339 string", "en", `{{formatDate "2026-03-01T09:05:00Z"}}`, "Mar 1, 2026"},
+		{"number zh", "zh-CN", `{{formatNumber .Count}} {{formatNumber 2 .Amount}}`, "3 1,234,567.50"},
+		{"number de", "de-DE", `{{formatNumber .Amount}}`, "1.234.567,5"},
+		{"money zh", "zh-CN", `{{formatMoney "CNY" .Amount}}`, "¥1,234,567.50"},
+		{"money de", "de", `{{formatMoney "EUR" .Amount}}`, "1.234.567,50\u00a0€"},
+		{"money jpy", "ja", `{{formatMoney "JPY" 1500}}`, "¥1,500"},
+		{"pluralize en", "en", `{{.Count}} {{pluralize .Count "item"}}, {{.One}} {{pluralize .One "child" "children"}}`, "3 items, 1 child"},
+		{"pluralize fr zero", "fr", `{{pluralize 0 "article" "articles"}}`, "article"},
+		{"pluralize zh", "zh-CN", `{{pluralize .Count "件"}}`, "件"},
+		{"truncate", "zh-CN", `{{truncate 5 .Title}}`, "一二三四五…"},
+		{"default", "zh-CN", `{{.Empty | default "客户"}} {{.Missing | default "-"}}`, "客户 -"},
+		{"upper", "en", `{{upper "abc"}}`, "ABC"},
+		{"upper turkish", "tr", `{{upper "i"}}`, "İ"},
+		{"urlquery", "en", `{{urlquery .Query}}`, "a+b%26c"},
+		{"time ago zh", "zh-CN", `{{timeAgo .Recent}}`, "3 分钟前"},
+		{"time ago en", "en-US", `{{timeAgo .Recent}}`, "3 minutes ago"},
+		{"time ago ja", "ja", `{{timeAgo .Recent}}`, "3分前"},
+		{"time ago days", "en", `{{timeAgo .At}}`, "14 days ago"},
+		{"time ago future", "zh-CN", `{{timeAgo .Later}}`, "2 小时后"},
+		{"time ago de", "de-DE", `{{timeAgo .Recent}}`, "vor 3 Minuten"},
+		{"time ago es days", "es", `{{timeAgo .At}}`, "hace 14 días"},
+		{"time ago fr future", "fr", `{{timeAgo .Later}}`, "dans 2 heures"},
+		{"time ago default language", "", `{{timeAgo .Recent}}`, "3 分钟前"},
+		{"unconfigured language pt", "pt-BR", `{{formatNumber 2 .Amount}} {{timeAgo .Recent}}`, "1,234,567.50 3 minutes ago"},
+		{"unconfigured language ru", "ru", `{{timeAgo .Later}}`, "in 2 hours"},
+		{"placeholder passthrough", "zh-CN", `{{formatDate "{{.At}}"}}`, "{{.At}}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := RenderOptions{Language: tt.language}
+			result, err := engine.RenderWith(tt.template, params, opts)
+			if err != nil {
+				t.Fatalf("RenderWith: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("text: expected %q, got %q", tt.expected, result)
+			}
+
+			// HTML 模式可使用相同函数
+			if _, err := engine.RenderHTMLWith(tt.template, params, opts); err != nil {
+				t.Errorf("RenderHTMLWith: %v", err)
+			}
+		})
+	}
+}
+
+func TestTemplateEngine_FuncsLanguageCache(t *testing.T) {
+	engine := NewTemplateEngine()
+	params := map[string]any{"N": 1234.5}
+
+	for language, expected := range map[string]string{"zh-CN": "1,234.5", "de": "1.234,5", "fr": "1\u00a0234,5"} {
+		result, err := engine.RenderWith(`{{formatNumber .N}}`, params, RenderOptions{Language: language, CacheKey: "tpl:1:v1"})
+		if err != nil {
+			t.Fatalf("RenderWith: %v", err)
+		}
+		if result != expected {
+			t.Errorf("%s: expected %q, got %q", language, expected, result)
+		}
+	}
+}
+
+func TestTemplateEngine_RegisterFunc(t *testing.T) {
+	engine := NewTemplateEngine()
+
+	// 注册前解析失败
+	if _, err := engine.Render(`{{shout .Name}}`, map[string]any{"Name": "hi"}); err == nil {
+		t.Fatal("expected error for unknown function")
+	}
+
+	if err := engine.RegisterFunc("shout", func(s string) string { return strings.ToUpper(s) + "!" }); err != nil {
+		t.Fatalf("RegisterFunc: %v", err)
+	}
+	result, err := engine.RenderHTML(`<b>{{shout .Name}}</b>`, map[string]any{"Name": "hi"})
+	if err != nil {
+		t.Fatalf("RenderHTML: %v", err)
+	}
+	if result != "<b>HI!</b>" {
+		t.Errorf("unexpected result: %q", result)
+	}
+
+	// 覆盖内置格式化函数
+	if err := engine.RegisterFunc("upper", func(v any) string { return "X" }); err != nil {
+		t.Fatalf("RegisterFunc: %v", err)
+	}
+	if result, _ := engine.Render(`{{upper "a"}}`, nil); result != "X" {
+		t.Errorf("expected override, got %q", result)
+	}
+
+	invalid := []struct {
+		name string
+		fn   any
+	}{
+		{"safeHTML", func(v any) string { return "" }},
+		{"bad-name", func() string { return "" }},
+		{"notFunc", "value"},
+		{"noResult", func() {}},
+		{"badError", func() (string, string) { return "", "" }},
+	}
+	for _, tt := range invalid {
+		if err := engine.RegisterFunc(tt.name, tt.fn); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
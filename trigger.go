@@ -5,18 +5,26 @@ import "sync"
 // Param 参数定义
 type Param struct {
 	Name        string `json:"name"`
-	Type        string `json:"type"`        // string, number, url, datetime, array
+	Type        string `json:"type"` // string, number, url, datetime, array
 	Description string `json:"description"`
 	Required    bool   `json:"required"`
 	Example     string `json:"example"` // 示例值（用于预览）
+	Trusted     bool   `json:"trusted"` // 可信参数（HTML 正文中允许 safeHTML/safeURL 跳过转义）
 }
 
 // TriggerDefinition 触发点定义
 type TriggerDefinition struct {
-	Code        string  `json:"code"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Params      []Param `json:"params"`
+	Code        string       `json:"code"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Params      []Param      `json:"params"`
+	RetryPolicy *RetryPolicy `json:"retry_policy,omitempty"` // 重试策略（为空时使用服务默认策略）
+}
+
+// WithRetryPolicy 设置触发点专属重试策略（应在注册阶段调用）
+func (d *TriggerDefinition) WithRetryPolicy(policy RetryPolicy) *TriggerDefinition {
+	d.RetryPolicy = &policy
+	return d
 }
 
 // TriggerRegistry 触发点注册表
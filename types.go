@@ -1,47 +1,60 @@
 package email_notification
 
-import "github.com/KOMKZ/go-yogan-domain-email-notification/model"
+import (
+	"time"
+
+	"github.com/KOMKZ/go-yogan-domain-email-notification/model"
+)
 
 // CreateTemplateInput 创建模板输入
 type CreateTemplateInput struct {
-	TriggerCode string             `json:"trigger_code"`
-	Language    string             `json:"language"`
-	Name        string             `json:"name"`
-	Subject     string             `json:"subject"`
-	BodyHTML    string             `json:"body_html"`
-	BodyText    string             `json:"body_text"`
-	Status      model.TemplateStatus `json:"status"`
-	Cc          string             `json:"cc"`
-	Bcc         string             `json:"bcc"`
-	ReplyTo     string             `json:"reply_to"`
+	TriggerCode string               `json:"trigger_code"`
+	Language    string               `json:"language"`
+	Name        string               `json:"name"`
+	Subject     string               `json:"subject"`
+	BodyHTML    string               `json:"body_html"`
+	BodyText    string               `json:"body_text"`
+	Status      model.TemplateStatus `json:"status"` // 仅支持 draft（默认），启用须提交审核
+	Layout      string               `json:"layout"` // 布局名称（可选）
+	Cc          string               `json:"cc"`
+	Bcc         string               `json:"bcc"`
+	ReplyTo     string               `json:"reply_to"`
 }
 
 // UpdateTemplateInput 更新模板输入
 type UpdateTemplateInput struct {
-	Name     *string             `json:"name"`
-	Subject  *string             `json:"subject"`
-	BodyHTML *string             `json:"body_html"`
-	BodyText *string             `json:"body_text"`
+	Name     *string               `json:"name"`
+	Subject  *string               `json:"subject"`
+	BodyHTML *string               `json:"body_html"`
+	BodyText *string               `json:"body_text"`
 	Status   *model.TemplateStatus `json:"status"`
-	Cc       *string             `json:"cc"`
-	Bcc      *string             `json:"bcc"`
-	ReplyTo  *string             `json:"reply_to"`
+	Cc       *string               `json:"cc"`
+	Bcc      *string               `json:"bcc"`
+	ReplyTo  *string               `json:"reply_to"`
+	Layout   *string               `json:"layout"` // 布局名称（空字符串表示取消布局）
+
+	Revision   *int   `json:"revision"`    // 期望的乐观锁修订号（取自读取时的 template.revision，不一致时返回 ErrTemplateConflict）
+	ChangeNote string `json:"change_note"` // 修订说明（内容变更时记录到版本）
+	Publish    bool   `json:"publish"`     // 是否立即发布本次修订（默认仅保存为草稿修订）
 }
 
 // SendInput 发送输入
 type SendInput struct {
 	TriggerCode string         // 触发点代码（必填）
-	Recipient   string         // 收件人（必填，可逗号分隔多个）
-	Language    string         // 语言（可选，默认 zh-CN）
+	Recipient   string         // 收件人（必填，可逗号分隔多个，支持 "Name <addr>"，每个收件人单独发送）
+	Language    string         // 语言（可选，BCP 47 标签，如 pt-BR；缺少对应模板时按回退链回退，见 WithLanguageFallback）
 	Params      map[string]any // 参数（通用+Trigger 专属）
 
+	// 幂等键（可选）：有效期内以相同键重复 Send/SendAsync 时不再发送，返回原发送结果（见 WithIdempotencyWindow）
+	IdempotencyKey string
+
 	// 以下字段可覆盖模板配置
 	Cc          []string     // 抄送（追加到模板配置）
 	Bcc         []string     // 密送（追加到模板配置）
 	ReplyTo     string       // 回复地址（覆盖模板配置）
 	From        string       // 发件人（覆盖默认配置）
 	FromName    string       // 发件人名称
-	Subject     string       // 主题（覆盖模板，用于特殊场景）
+	Subject     string       // 主题（覆盖模板，用于特殊场景，支持模板语法）
 	Attachments []Attachment // 附件
 }
 
@@ -52,6 +65,55 @@ type Attachment struct {
 	ContentType string // MIME 类型
 }
 
+// CreatePartialInput 创建布局或片段输入
+type CreatePartialInput struct {
+	Name        string            `json:"name"`     // 名称（模板中以 {{template "name" .}} 或 Layout 引用）
+	Language    string            `json:"language"` // 语言（默认 zh-CN，缺少对应语言时按回退链回退）
+	Kind        model.PartialKind `json:"kind"`     // partial（默认）或 layout
+	Description string            `json:"description"`
+	BodyHTML    string            `json:"body_html"`
+	BodyText    string            `json:"body_text"`
+}
+
+// UpdatePartialInput 更新布局或片段输入
+type UpdatePartialInput struct {
+	Description *string `json:"description"`
+	BodyHTML    *string `json:"body_html"`
+	BodyText    *string `json:"body_text"`
+}
+
+// ResendOptions 重发选项
+type ResendOptions struct {
+	UseCurrentVersion bool   // 使用模板当前已发布版本重新渲染（默认使用原日志记录的版本）
+	Recipient         string // 覆盖收件人（可选，仅单条重发，如用户更换了邮箱）
+	Async             bool   // 入队由 Worker 投递（批量重发建议开启）
+}
+
+// ResendItem 单条重发结果
+type ResendItem struct {
+	LogID    uint             `json:"log_id"`               // 原日志
+	NewLogID uint             `json:"new_log_id,omitempty"` // 重发创建的日志
+	Status   model.SendStatus `json:"status,omitempty"`     // 新日志状态
+	Error    string           `json:"error,omitempty"`      // 未重发的原因
+}
+
+// ResendResult 批量重发结果
+type ResendResult struct {
+	Resent  []ResendItem `json:"resent"`  // 已创建新日志（含发送失败的）
+	Skipped []ResendItem `json:"skipped"` // 未重发（如发送中、模板不存在）
+}
+
+// RequeueReport 失败日志重新入队报告
+type RequeueReport struct {
+	Scanned         int          `json:"scanned"`                     // 已扫描的失败日志数
+	Requeued        int          `json:"requeued"`                    // 已重新入队
+	Deduplicated    int          `json:"deduplicated"`                // 存在更新的日志而跳过
+	Skipped         []ResendItem `json:"skipped"`                     // 无法重发（如模板不存在）
+	Cursor          uint         `json:"cursor"`                      // 最后处理的日志 ID
+	LastAvailableAt *time.Time   `json:"last_available_at,omitempty"` // 最后一封的计划投递时间
+	Done            bool         `json:"done"`                        // 是否已处理全部失败日志
+}
+
 // PreviewResult 预览结果
 type PreviewResult struct {
 	Subject  string `json:"subject"`
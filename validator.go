@@ -0,0 +1,174 @@
+package email_notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 参数类型
+const (
+	ParamTypeString   = "string"
+	ParamTypeNumber   = "number"
+	ParamTypeURL      = "url"
+	ParamTypeDatetime = "datetime"
+	ParamTypeArray    = "array"
+)
+
+// ParamViolation 单个参数校验错误
+type ParamViolation struct {
+	Param  string `json:"param"`
+	Reason string `json:"reason"`
+}
+
+// ParamValidationError 参数校验失败（列出全部不合法参数）
+type ParamValidationError struct {
+	Violations []ParamViolation `json:"violations"`
+}
+
+func (e *ParamValidationError) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		parts = append(parts, v.Param+": "+v.Reason)
+	}
+	return "参数校验失败: " + strings.Join(parts, "; ")
+}
+
+// ValidateParams 按参数定义校验参数，返回参数副本
+// 仅校验类型，渲染使用调用方传入的原值（如 RFC3339 字符串、"007"），格式化函数按需转换
+// 校验失败时返回 ErrInvalidInput，可通过 errors.As 取得 *ParamValidationError
+func ValidateParams(defs []Param, params map[string]any) (map[string]any, error) {
+	result := make(map[string]any, len(params))
+	for k, v := range params {
+		result[k] = v
+	}
+
+	var violations []ParamViolation
+	for _, def := range defs {
+		value, ok := result[def.Name]
+		if !ok || isEmptyParam(value) {
+			if def.Required {
+				violations = append(violations, ParamViolation{Param: def.Name, Reason: "必填参数缺失"})
+			}
+			continue
+		}
+
+		if reason := checkParam(def.Type, value); reason != "" {
+			violations = append(violations, ParamViolation{Param: def.Name, Reason: reason})
+		}
+	}
+
+	if len(violations) > 0 {
+		verr := &ParamValidationError{Violations: violations}
+		return nil, ErrInvalidInput.WithMsg(verr.Error()).Wrap(verr)
+	}
+	return result, nil
+}
+
+// isEmptyParam 是否为空值（nil 或空字符串）
+func isEmptyParam(value any) bool {
+	if value == nil {
+		return true
+	}
+	if s, ok := value.(string); ok {
+		return strings.TrimSpace(s) == ""
+	}
+	return false
+}
+
+// checkParam 校验参数值类型，失败时返回原因
+func checkParam(typ string, value any) string {
+	switch typ {
+	case ParamTypeNumber:
+		return checkNumber(value)
+	case ParamTypeURL:
+		return checkURL(value)
+	case ParamTypeDatetime:
+		return checkDatetime(value)
+	case ParamTypeArray:
+		if isCollection(value, reflect.Slice, reflect.Array) {
+			return ""
+		}
+		return "必须为数组"
+	case ParamTypeString:
+		if isCollection(value, reflect.Slice, reflect.Array, reflect.Map, reflect.Struct) {
+			if _, ok := value.(fmt.Stringer); !ok {
+				return "必须为字符串"
+			}
+		}
+		return ""
+	default:
+		// 未声明或未知类型不校验
+		return ""
+	}
+}
+
+func checkNumber(value any) string {
+	switch v := value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return ""
+	case json.Number:
+		if _, err := v.Float64(); err == nil {
+			return ""
+		}
+	case string:
+		if _, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return ""
+		}
+	}
+	return "必须为数字"
+}
+
+func checkURL(value any) string {
+	var raw string
+	switch v := value.(type) {
+	case string:
+		raw = strings.TrimSpace(v)
+	case *url.URL:
+		raw = v.String()
+	case url.URL:
+		raw = v.String()
+	default:
+		return "必须为 URL 字符串"
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || (u.Host == "" && u.Opaque == "") {
+		return "不是合法的绝对 URL"
+	}
+	return ""
+}
+
+func checkDatetime(value any) string {
+	switch v := value.(type) {
+	case time.Time:
+		return ""
+	case *time.Time:
+		if v != nil {
+			return ""
+		}
+	case string:
+		if _, err := time.Parse(time.RFC3339, strings.TrimSpace(v)); err == nil {
+			return ""
+		}
+		return "必须为 RFC3339 格式时间"
+	}
+	return "必须为时间"
+}
+
+func isCollection(value any, kinds ...reflect.Kind) bool {
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	for _, k := range kinds {
+		if rv.Kind() == k {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package email_notification
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidateParams(t *testing.T) {
+	defs := []Param{
+		{Name: "UserName", Type: ParamTypeString, Required: true},
+		{Name: "Amount", Type: ParamTypeNumber},
+		{Name: "Link", Type: ParamTypeURL, Required: true},
+		{Name: "ExpireAt", Type: ParamTypeDatetime},
+		{Name: "Items", Type: ParamTypeArray},
+	}
+
+	params, err := ValidateParams(defs, map[string]any{
+		"UserName": "张三",
+		"Amount":   "12.5",
+		"Link":     "https://example.com/verify?token=abc",
+		"ExpireAt": time.Now(),
+		"Items":    []string{"a", "b"},
+		"Extra":    "kept",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if params["Amount"] != "12.5" {
+		t.Errorf("expected Amount to keep its original value, got %#v", params["Amount"])
+	}
+	if params["Extra"] != "kept" {
+		t.Error("expected undeclared params to be kept")
+	}
+}
+
+func TestValidateParams_RendersOriginalValues(t *testing.T) {
+	defs := []Param{
+		{Name: "Code", Type: ParamTypeNumber},
+		{Name: "ExpireAt", Type: ParamTypeDatetime},
+	}
+
+	// 校验通过的参数按调用方传入的原值渲染，格式化函数按需转换
+	params, err := ValidateParams(defs, map[string]any{"Code": "007", "ExpireAt": "2026-03-01T09:05:00Z"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := NewTemplateEngine().Render(`{{.Code}} {{.ExpireAt}} {{formatNumber .Code}} {{formatDate "2006-01-02" .ExpireAt}}`, params)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if want := "007 2026-03-01T09:05:00Z 7 2026-03-01"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestValidateParams_Violations(t *testing.T) {
+	defs := []Param{
+		{Name: "UserName", Type: ParamTypeString, Required: true},
+		{Name: "Amount", Type: ParamTypeNumber},
+		{Name: "Link", Type: ParamTypeURL},
+		{Name: "ExpireAt", Type: ParamTypeDatetime},
+		{Name: "Items", Type: ParamTypeArray},
+	}
+
+	_, err := ValidateParams(defs, map[string]any{
+		"UserName": "",
+		"Amount":   "abc",
+		"Link":     "/relative/path",
+		"ExpireAt": "2026-01-02 15:04",
+		"Items":    "a,b",
+	})
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput, got %v", err)
+	}
+
+	var verr *ParamValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected *ParamValidationError, got %T", err)
+	}
+	if len(verr.Violations) != 5 {
+		t.Errorf("expected 5 violations, got %d: %v", len(verr.Violations), verr.Violations)
+	}
+}
@@ -0,0 +1,152 @@
+package email_notification
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/KOMKZ/go-yogan-domain-email-notification/model"
+)
+
+// WorkerOptions 异步发送 Worker 配置
+type WorkerOptions struct {
+	WorkerID      string        // Worker 标识（默认 hostname-pid）
+	Concurrency   int           // 并发协程数（默认 4）
+	BatchSize     int           // 每次领取任务数（默认 1）
+	PollInterval  time.Duration // 队列为空时的轮询间隔（默认 1s）
+	LeaseDuration time.Duration // 任务租约时长，超时未完成可被重新领取（默认 5m）
+	MaxAttempts   int           // 任务最大领取次数（含重试与租约过期后的重新领取，须大于重试策略的最大尝试次数），超过后任务失败不再投递（默认 10）
+}
+
+// Worker 异步发送 Worker，从发送队列领取任务并执行
+type Worker struct {
+	svc  *Service
+	opts WorkerOptions
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewWorker 创建 Worker
+func NewWorker(svc *Service, opts WorkerOptions) *Worker {
+	if opts.WorkerID == "" {
+		host, _ := os.Hostname()
+		opts.WorkerID = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 4
+	}
+	if opts.BatchSize < 1 {
+		opts.BatchSize = 1
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.LeaseDuration <= 0 {
+		opts.LeaseDuration = 5 * time.Minute
+	}
+	if opts.MaxAttempts < 1 {
+		opts.MaxAttempts = 10
+	}
+	return &Worker{svc: svc, opts: opts}
+}
+
+// Start 启动 Worker 协程池（非阻塞）
+func (w *Worker) Start(ctx context.Context) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.cancel != nil {
+		return
+	}
+
+	ctx, w.cancel = context.WithCancel(ctx)
+	for i := 0; i < w.opts.Concurrency; i++ {
+		w.wg.Add(1)
+		go w.loop(ctx, fmt.Sprintf("%s#%d", w.opts.WorkerID, i))
+	}
+}
+
+// Stop 停止 Worker，等待执行中的任务完成
+func (w *Worker) Stop() {
+	w.mu.Lock()
+	cancel := w.cancel
+	w.cancel = nil
+	w.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		w.wg.Wait()
+	}
+}
+
+// RunOnce 领取并执行一批任务，返回处理的任务数（适用于定时任务或测试）
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	return w.runBatch(ctx, w.opts.WorkerID)
+}
+
+func (w *Worker) loop(ctx context.Context, workerID string) {
+	defer w.wg.Done()
+
+	for {
+		n, err := w.runBatch(ctx, workerID)
+		if n > 0 && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.opts.PollInterval):
+		}
+	}
+}
+
+func (w *Worker) runBatch(ctx context.Context, workerID string) (int, error) {
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+
+	jobs, err := w.svc.jobRepo.Claim(ctx, workerID, w.opts.BatchSize, w.svc.clock.Now(), w.opts.LeaseDuration)
+	if err != nil {
+		w.svc.logger.ErrorContext(ctx, "claim email send jobs failed", "worker_id", workerID, "error", err)
+		return 0, err
+	}
+
+	for _, job := range jobs {
+		// 已领取的任务不受 ctx 取消影响，避免发送到一半被中断
+		jobCtx := context.WithoutCancel(ctx)
+		lockedBy, lockedUntil := job.LockedBy, *job.LockedUntil
+
+		var (
+			sendLog *model.SendLog
+			err     error
+		)
+		if job.Attempts > w.opts.MaxAttempts {
+			// 反复领取仍未完成（如任务导致 Worker 崩溃、租约过期）：转为失败，不再投递
+			sendLog, err = w.svc.abandonJob(jobCtx, job, ErrSendFailed.WithMsg(fmt.Sprintf("任务已领取 %d 次仍未完成", job.Attempts)))
+		} else {
+			sendLog, err = w.svc.processJob(jobCtx, job)
+		}
+		switch {
+		case err == nil:
+			job.MarkDone()
+		case sendLog != nil && sendLog.Status == model.SendStatusRetrying:
+			job.Requeue(*sendLog.NextAttemptAt, err.Error())
+		default:
+			job.MarkFailed(err.Error())
+			w.svc.logger.WarnContext(jobCtx, "email send job failed", "job_id", job.ID, "send_log_id", job.SendLogID, "error", err)
+		}
+		// 按领取时的租约写回，租约已被其他 Worker 接管时不覆盖对方的领取
+		released, err := w.svc.jobRepo.Release(jobCtx, job, lockedBy, lockedUntil)
+		if err != nil {
+			return len(jobs), ErrDatabaseError.Wrap(err)
+		}
+		if !released {
+			w.svc.logger.WarnContext(jobCtx, "email send job lease lost", "job_id", job.ID, "worker_id", lockedBy)
+		}
+	}
+	return len(jobs), nil
+}
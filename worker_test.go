@@ -0,0 +1,412 @@
+package email_notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KOMKZ/go-yogan-domain-email-notification/model"
+	"gorm.io/gorm"
+)
+
+// newQueueTestService 使用 SQLite 构建服务并创建审核通过的 user:registered 模板
+func newQueueTestService(t *testing.T, db *gorm.DB) *Service {
+	t.Helper()
+
+	registry := NewTriggerRegistry()
+	registry.Register("user:registered", "用户注册", "", []Param{{Name: "UserName", Type: "string", Required: true}})
+	svc := NewService(db, nil, registry, map[string]any{"AppName": "Yogan"})
+	tpl, err := svc.CreateTemplate(context.Background(), CreateTemplateInput{
+		TriggerCode: "user:registered",
+		Name:        "注册欢迎",
+		Subject:     "欢迎加入 {{.AppName}}",
+		BodyHTML:    "<p>Hi {{.UserName}}</p>",
+	})
+	if err != nil {
+		t.Fatalf("create template: %v", err)
+	}
+	approveTemplate(t, svc, tpl.ID)
+	return svc
+}
+
+func TestWorker_Claim(t *testing.T) {
+	svc := newQueueTestService(t, newTestDB(t))
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if err := svc.SendAsync(ctx, SendInput{TriggerCode: "user:registered", Recipient: "a@example.com", Params: map[string]any{"UserName": "Tom"}}); err != nil {
+			t.Fatalf("send async: %v", err)
+		}
+	}
+	logs, _ := svc.GetSendLogs(ctx, LogFilter{Status: model.SendStatusQueued})
+	if logs.Total != 3 || logs.Items[0].Subject != "欢迎加入 Yogan" {
+		t.Fatalf("expected 3 queued logs with rendered subject, got %+v", logs)
+	}
+
+	// 不同 Worker 领取的任务互不重复
+	first, err := svc.jobRepo.Claim(ctx, "w1", 2, time.Now(), time.Minute)
+	if err != nil || len(first) != 2 {
+		t.Fatalf("expected 2 jobs, got %d (%v)", len(first), err)
+	}
+	second, _ := svc.jobRepo.Claim(ctx, "w2", 2, time.Now(), time.Minute)
+	if len(second) != 1 || second[0].ID == first[0].ID || second[0].ID == first[1].ID {
+		t.Fatalf("expected the remaining job, got %+v", second)
+	}
+	if second[0].Status != model.JobStatusProcessing || second[0].LockedBy != "w2" || second[0].Attempts != 1 {
+		t.Errorf("unexpected claimed job: %+v", second[0])
+	}
+	if jobs, _ := svc.jobRepo.Claim(ctx, "w3", 2, time.Now(), time.Minute); len(jobs) != 0 {
+		t.Errorf("expected leased jobs to be skipped, got %d", len(jobs))
+	}
+
+	// 租约过期（Worker 崩溃）后任务可被重新领取
+	expired := time.Now().Add(-time.Second)
+	second[0].LockedUntil = &expired
+	if err := svc.jobRepo.Update(ctx, second[0]); err != nil {
+		t.Fatalf("update job: %v", err)
+	}
+	jobs, _ := svc.jobRepo.Claim(ctx, "w3", 2, time.Now(), time.Minute)
+	if len(jobs) != 1 || jobs[0].ID != second[0].ID || jobs[0].LockedBy != "w3" || jobs[0].Attempts != 2 {
+		t.Errorf("expected expired job to be reclaimed, got %+v", jobs)
+	}
+}
+
+func TestWorker_StartStop(t *testing.T) {
+	svc, sender := newTestService(t)
+	ctx := context.Background()
+
+	const total = 20
+	for i := 0; i < total; i++ {
+		if err := svc.SendAsync(ctx, SendInput{TriggerCode: "user:registered", Recipient: "a@example.com", Params: map[string]any{"UserName": "Tom"}}); err != nil {
+			t.Fatalf("send async: %v", err)
+		}
+	}
+
+	worker := NewWorker(svc, WorkerOptions{Concurrency: 4, BatchSize: 2, PollInterval: 10 * time.Millisecond})
+	worker.Start(ctx)
+	worker.Start(ctx) // 重复启动无效
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sender.Messages()) < total && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	worker.Stop()
+	worker.Stop()
+
+	// 多个协程并发领取，每个任务只投递一次
+	if n := len(sender.Messages()); n != total {
+		t.Fatalf("expected %d messages, got %d", total, n)
+	}
+	if n, err := worker.RunOnce(ctx); err != nil || n != 0 {
+		t.Errorf("expected empty queue, got %d (%v)", n, err)
+	}
+}
+
+func TestWorker_ConcurrentRunOnce(t *testing.T) {
+	svc, sender := newTestService(t)
+	ctx := context.Background()
+
+	const total = 10
+	for i := 0; i < total; i++ {
+		svc.SendAsync(ctx, SendInput{TriggerCode: "user:registered", Recipient: "a@example.com", Params: map[string]any{"UserName": "Tom"}})
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			worker := NewWorker(svc, WorkerOptions{BatchSize: 3})
+			for {
+				if n, err := worker.RunOnce(ctx); err != nil || n == 0 {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := len(sender.Messages()); n != total {
+		t.Errorf("expected %d messages, got %d", total, n)
+	}
+}
+
+func TestWorker_MaxAttempts(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	svc, sender := newTestService(t, WithClock(ClockFunc(func() time.Time { return now })))
+	ctx := context.Background()
+
+	if err := svc.SendAsync(ctx, SendInput{TriggerCode: "user:registered", Recipient: "a@example.com", Params: map[string]any{"UserName": "Tom"}}); err != nil {
+		t.Fatalf("send async: %v", err)
+	}
+
+	// 任务两次导致 Worker 崩溃：领取后未写回，租约过期
+	for i := 0; i < 2; i++ {
+		if jobs, _ := svc.jobRepo.Claim(ctx, "crashed", 1, now, time.Minute); len(jobs) != 1 {
+			t.Fatalf("expected job to be claimed, got %d", len(jobs))
+		}
+		now = now.Add(2 * time.Minute)
+	}
+
+	// 超过最大领取次数：任务失败，不再投递
+	worker := NewWorker(svc, WorkerOptions{MaxAttempts: 2})
+	if n, err := worker.RunOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("expected 1 job processed, got %d (%v)", n, err)
+	}
+	if len(sender.Messages()) != 0 {
+		t.Errorf("expected no message, got %d", len(sender.Messages()))
+	}
+	failed, _ := svc.GetSendLogs(ctx, LogFilter{Status: model.SendStatusFailed})
+	if failed.Total != 1 || !strings.Contains(failed.Items[0].ErrorMessage, "3 次") {
+		t.Errorf("expected failed log, got %+v", failed.Items)
+	}
+	now = now.Add(time.Hour)
+	if n, _ := worker.RunOnce(ctx); n != 0 {
+		t.Errorf("expected dead job not to be claimed again, got %d", n)
+	}
+}
+
+func TestWorker_ReclaimedSentJob(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	svc, sender := newTestService(t, WithClock(ClockFunc(func() time.Time { return now })))
+	ctx := context.Background()
+
+	if err := svc.SendAsync(ctx, SendInput{TriggerCode: "user:registered", Recipient: "a@example.com", Params: map[string]any{"UserName": "Tom"}}); err != nil {
+		t.Fatalf("send async: %v", err)
+	}
+
+	// Worker 发送成功后、写回任务前崩溃
+	jobs, _ := svc.jobRepo.Claim(ctx, "crashed", 1, now, time.Minute)
+	sendLog, _ := svc.logRepo.GetByID(ctx, jobs[0].SendLogID)
+	sendLog.MarkSent(now)
+	svc.logRepo.Update(ctx, sendLog)
+
+	// 租约过期后重新领取：不重复投递，任务完成
+	now = now.Add(2 * time.Minute)
+	worker := NewWorker(svc, WorkerOptions{})
+	if n, err := worker.RunOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("expected 1 job processed, got %d (%v)", n, err)
+	}
+	if len(sender.Messages()) != 0 {
+		t.Errorf("expected no duplicate message, got %d", len(sender.Messages()))
+	}
+	now = now.Add(time.Hour)
+	if n, _ := worker.RunOnce(ctx); n != 0 {
+		t.Errorf("expected done job not to be claimed again, got %d", n)
+	}
+}
+
+func TestWorker_ParamTypes(t *testing.T) {
+	svc, sender := newTestService(t)
+	svc.registry.Register("order:shipped", "订单发货", "", []Param{
+		{Name: "OrderID", Type: ParamTypeNumber},
+		{Name: "ShippedAt", Type: ParamTypeDatetime},
+		{Name: "Stamp", Type: ParamTypeNumber},
+	})
+	ctx := WithActor(context.Background(), "alice")
+	tpl, err := svc.CreateTemplate(ctx, CreateTemplateInput{
+		TriggerCode: "order:shipped",
+		Name:        "发货通知",
+		Subject:     "订单 {{.OrderID}}",
+		BodyHTML:    `<p>{{.OrderID}} {{.ShippedAt.Year}} {{.ShippedAt | formatDate "2006-01-02"}} {{.Stamp | formatDate "2006"}} {{if eq .OrderID 9007199254740993}}big{{end}}</p>`,
+	})
+	if err != nil {
+		t.Fatalf("create template: %v", err)
+	}
+	approveTemplate(t, svc, tpl.ID)
+
+	// 大整数、时间与 Unix 时间戳经队列持久化后，渲染结果与同步发送一致
+	input := SendInput{TriggerCode: "order:shipped", Recipient: "a@example.com", Params: map[string]any{
+		"OrderID":   int64(9007199254740993),
+		"ShippedAt": time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC),
+		"Stamp":     1700000000,
+	}}
+	if err := svc.Send(ctx, input); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if err := svc.SendAsync(ctx, input); err != nil {
+		t.Fatalf("send async: %v", err)
+	}
+	if n, err := NewWorker(svc, WorkerOptions{}).RunOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("expected 1 job processed, got %d (%v)", n, err)
+	}
+
+	messages := sender.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	want := "<p>9007199254740993 2026 2026-03-01 2023 big</p>"
+	for _, msg := range messages {
+		if msg.HTMLBody != want || msg.Subject != "订单 9007199254740993" {
+			t.Errorf("expected %q, got %q (subject %q)", want, msg.HTMLBody, msg.Subject)
+		}
+	}
+}
+
+func TestWorker_ParamsRoundTrip(t *testing.T) {
+	shippedAt := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	params := map[string]any{
+		"OrderID":   int64(9007199254740993),
+		"Amount":    12.5,
+		"Stamp":     1700000000,
+		"ShippedAt": shippedAt,
+		"Items":     []any{map[string]any{"Qty": 2, "At": &shippedAt}},
+		"UserName":  "Tom",
+	}
+
+	// 与发送日志相同的持久化方式：大整数、浮点数与时间经 JSON 后类型不变
+	data, err := json.Marshal(persistParams(params))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	restored := make(map[string]any)
+	if err := decodeJSON(string(data), &restored); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	restored = restoreParams(restored)
+
+	if restored["OrderID"] != int64(9007199254740993) || restored["Amount"] != 12.5 || restored["Stamp"] != int64(1700000000) || restored["UserName"] != "Tom" {
+		t.Errorf("unexpected scalars: %#v", restored)
+	}
+	if at, ok := restored["ShippedAt"].(time.Time); !ok || !at.Equal(shippedAt) {
+		t.Errorf("expected time.Time, got %#v", restored["ShippedAt"])
+	}
+	item := restored["Items"].([]any)[0].(map[string]any)
+	if at, ok := item["At"].(time.Time); !ok || !at.Equal(shippedAt) || item["Qty"] != int64(2) {
+		t.Errorf("unexpected nested values: %#v", item)
+	}
+
+	// 渲染结果与原始参数一致
+	engine := NewTemplateEngine()
+	tpl := "{{.OrderID}} {{.ShippedAt.Year}} {{.Amount}}"
+	want, _ := engine.Render(tpl, params)
+	if got, err := engine.Render(tpl, restored); err != nil || got != want {
+		t.Errorf("expected %q, got %q (%v)", want, got, err)
+	}
+}
+
+func TestService_WithTx(t *testing.T) {
+	db := newTestDB(t)
+	svc := newQueueTestService(t, db)
+	ctx := context.Background()
+	input := SendInput{TriggerCode: "user:registered", Recipient: "a@example.com", Params: map[string]any{"UserName": "Tom"}}
+	count := func(value any) int64 {
+		var n int64
+		db.Model(value).Count(&n)
+		return n
+	}
+
+	// 调用方事务回滚：不留下日志与队列任务
+	rollback := errors.New("rollback")
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := svc.EnqueueInTx(ctx, tx, input); err != nil {
+			return err
+		}
+		if err := svc.WithTx(tx).Send(ctx, input); err != nil {
+			return err
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("expected rollback, got %v", err)
+	}
+	if logs, jobs := count(&model.SendLog{}), count(&model.SendJob{}); logs != 0 || jobs != 0 {
+		t.Fatalf("expected no logs and jobs after rollback, got %d logs, %d jobs", logs, jobs)
+	}
+
+	// 提交后任务可被 Worker 领取
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := svc.EnqueueInTx(ctx, tx, input); err != nil {
+			return err
+		}
+		return svc.WithTx(tx).Send(ctx, input)
+	})
+	if err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if logs, jobs := count(&model.SendLog{}), count(&model.SendJob{}); logs != 2 || jobs != 2 {
+		t.Fatalf("expected 2 logs and jobs after commit, got %d logs, %d jobs", logs, jobs)
+	}
+	if jobs, err := svc.jobRepo.Claim(ctx, "w1", 10, time.Now(), time.Minute); err != nil || len(jobs) != 2 {
+		t.Errorf("expected 2 claimable jobs, got %d (%v)", len(jobs), err)
+	}
+}
+
+func TestWorker_DeliversQueuedVersion(t *testing.T) {
+	svc, sender := newTestService(t)
+	ctx := WithActor(context.Background(), "alice")
+	tpl, _ := svc.GetTemplateByTrigger(ctx, "user:registered", "zh-CN")
+	input := SendInput{TriggerCode: "user:registered", Recipient: "a@example.com", Params: map[string]any{"UserName": "Tom"}}
+	toDraft := func() {
+		t.Helper()
+		for _, status := range []model.TemplateStatus{model.TemplateStatusDisabled, model.TemplateStatusDraft} {
+			if _, err := svc.UpdateTemplate(ctx, tpl.ID, UpdateTemplateInput{Status: &status}); err != nil {
+				t.Fatalf("set status %s: %v", status, err)
+			}
+		}
+	}
+
+	// 版本 1 发送一次，解析结果进入缓存
+	if err := svc.Send(ctx, input); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	// 发布版本 2 并异步入队，入队后又改回发布版本 1
+	toDraft()
+	body := "<p>V2 {{.UserName}}</p>"
+	if _, err := svc.UpdateTemplate(ctx, tpl.ID, UpdateTemplateInput{BodyHTML: &body, Publish: true}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	approveTemplate(t, svc, tpl.ID)
+	if err := svc.SendAsync(ctx, input); err != nil {
+		t.Fatalf("send async: %v", err)
+	}
+	toDraft()
+	if _, err := svc.PublishVersion(ctx, tpl.ID, 1); err != nil {
+		t.Fatalf("publish version 1: %v", err)
+	}
+
+	// 任务按入队时的版本 2 渲染，不命中版本 1 的缓存
+	if n, err := NewWorker(svc, WorkerOptions{}).RunOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("expected 1 job processed, got %d (%v)", n, err)
+	}
+	if got := sender.Last().HTMLBody; got != "<p>V2 Tom</p>" {
+		t.Errorf("expected queued version 2 body, got %s", got)
+	}
+}
+
+func TestWorker_DeliversLegacyLogPublishedVersion(t *testing.T) {
+	svc, sender := newTestService(t)
+	ctx := WithActor(context.Background(), "alice")
+	tpl, _ := svc.GetTemplateByTrigger(ctx, "user:registered", "zh-CN")
+
+	if err := svc.SendAsync(ctx, SendInput{TriggerCode: "user:registered", Recipient: "a@example.com", Params: map[string]any{"UserName": "Tom"}}); err != nil {
+		t.Fatalf("send async: %v", err)
+	}
+
+	// 模拟版本化之前入队的日志：未记录模板版本
+	logs, _ := svc.GetSendLogs(ctx, LogFilter{Status: model.SendStatusQueued})
+	legacy := logs.Items[0]
+	legacy.TemplateVersion = 0
+	if err := svc.logRepo.Update(ctx, &legacy); err != nil {
+		t.Fatalf("update log: %v", err)
+	}
+
+	// 入队后保存未发布的修改
+	body := "<p>Draft {{.UserName}}</p>"
+	if _, err := svc.UpdateTemplate(ctx, tpl.ID, UpdateTemplateInput{BodyHTML: &body}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	// 未记录版本的日志按发布版本投递，不发送未发布内容
+	if n, err := NewWorker(svc, WorkerOptions{}).RunOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("expected 1 job processed, got %d (%v)", n, err)
+	}
+	if got := sender.Last().HTMLBody; got != "<p>Hi Tom</p>" {
+		t.Errorf("expected published body, got %s", got)
+	}
+}